@@ -0,0 +1,266 @@
+package gmock // nolint:golint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+// placeholders that can be used as values of a stub request JSON body to match any value of a given type.
+const (
+	anyStringPlaceholder  = "${json-unit.any-string}"  // matches any string
+	anyNumberPlaceholder  = "${json-unit.any-number}"  // matches any number
+	anyBooleanPlaceholder = "${json-unit.any-boolean}" // matches any boolean
+	ignorePlaceholder     = "${json-unit.ignore}"      // matches any value, including null
+)
+
+// requestBody is the body of an incoming request as it was received, along with whether it's a JSON document
+// according to its Content-Type (or to its content when there's none) and its compacted form when it is.
+type requestBody struct {
+	raw       string
+	json      bool
+	compacted string // the compacted JSON document, the raw body when it's not JSON
+}
+
+// newRequestBody returns the body of an incoming request with the given Content-Type.
+func newRequestBody(contentType string, b []byte) requestBody {
+	body := requestBody{raw: string(b), compacted: string(b)}
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !strings.HasSuffix(mediaType, "json") {
+			return body
+		}
+	}
+	if json.Valid(b) {
+		body.json = true
+		body.compacted = compactBody(b)
+	}
+	return body
+}
+
+// decodeJSON decodes the given JSON document keeping numbers as json.Number.
+func decodeJSON(s string) (any, error) {
+	var v any
+	d := json.NewDecoder(bytes.NewBufferString(s))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// matchJSON reports whether the actual JSON value matches the expected one.
+// When partial is set, objects of the actual value may have fields that are not in the expected value.
+// When ignoreArrayOrder is set, arrays match regardless of the order of their elements.
+func matchJSON(expected, actual any, partial, ignoreArrayOrder bool) bool {
+	switch e := expected.(type) {
+	case string:
+		switch e {
+		case ignorePlaceholder:
+			return true
+		case anyStringPlaceholder:
+			_, ok := actual.(string)
+			return ok
+		case anyNumberPlaceholder:
+			_, ok := actual.(json.Number)
+			return ok
+		case anyBooleanPlaceholder:
+			_, ok := actual.(bool)
+			return ok
+		}
+		a, ok := actual.(string)
+		return ok && a == e
+	case json.Number:
+		a, ok := actual.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == e {
+			return true
+		}
+		ef, err := e.Float64()
+		if err != nil {
+			return false
+		}
+		af, err := a.Float64()
+		return err == nil && af == ef
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		if !partial {
+			for k := range a {
+				if _, ok := e[k]; !ok {
+					return false
+				}
+			}
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok {
+				if s, isString := ev.(string); isString && s == ignorePlaceholder {
+					continue
+				}
+				return false
+			}
+			if !matchJSON(ev, av, partial, ignoreArrayOrder) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		if !ignoreArrayOrder {
+			for i := range e {
+				if !matchJSON(e[i], a[i], partial, ignoreArrayOrder) {
+					return false
+				}
+			}
+			return true
+		}
+		used := make([]bool, len(a))
+		for _, ev := range e {
+			found := false
+			for i, av := range a {
+				if !used[i] && matchJSON(ev, av, partial, ignoreArrayOrder) {
+					used[i] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return expected == actual
+	}
+}
+
+// BodyPattern is a predicate evaluated against the body of an incoming request.
+// All the predicates set must be satisfied for the body to match.
+//
+// JSONPath is a JSONPath expression evaluated against a JSON body, optionally followed by
+// a comparison operator (==, !=, >, >=, <, <=, =~) and a JSON value,
+// e.g. $.order.items[0].sku == "ABC" or $.amount > 100.
+// XPath is an XPath expression evaluated against an XML body, optionally followed by
+// a comparison operator (=, !=, >, >=, <, <=) and a quoted string or a number,
+// e.g. /order/sku = 'ABC' or //item[@id='1']/@quantity > 2.
+// Without an operator, both only require the path to select something.
+// Base64 and SHA256 match binary bodies by their base64 encoded content or their hex encoded SHA-256 digest.
+type BodyPattern struct {
+	JSONPath string `json:"json_path,omitempty" yaml:"json_path,omitempty"`
+	XPath    string `json:"xpath,omitempty" yaml:"xpath,omitempty"`
+	Base64   string `json:"base64,omitempty" yaml:"base64,omitempty"`
+	SHA256   string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+
+	jsonPath *jsonPathExpression // the parsed JSONPath, see compile
+	xpath    *xpathExpression    // the parsed XPath, see compile
+}
+
+// Validate returns a list of validation errors for the current body pattern.
+func (p *BodyPattern) Validate() []error {
+	var errs []error
+	if p.JSONPath != "" {
+		if _, err := parseJSONPathExpression(p.JSONPath); err != nil {
+			errs = append(errs, &ErrInvalidExpression{Expression: p.JSONPath, Err: err})
+		}
+	}
+	if p.XPath != "" {
+		if _, err := parseXPathExpression(p.XPath); err != nil {
+			errs = append(errs, &ErrInvalidExpression{Expression: p.XPath, Err: err})
+		}
+	}
+	if p.Base64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(p.Base64); err != nil {
+			errs = append(errs, &ErrInvalidExpression{Expression: p.Base64, Err: err})
+		}
+	}
+	return errs
+}
+
+// compile parses the expressions of a valid body pattern once, instead of on every match.
+func (p *BodyPattern) compile() {
+	p.jsonPath, p.xpath = nil, nil
+	if p.JSONPath != "" {
+		p.jsonPath, _ = parseJSONPathExpression(p.JSONPath)
+	}
+	if p.XPath != "" {
+		p.xpath, _ = parseXPathExpression(p.XPath)
+	}
+}
+
+// match reports whether the given body satisfies the body pattern.
+func (p *BodyPattern) match(body string) bool {
+	if p.JSONPath != "" {
+		e := p.jsonPath
+		if e == nil {
+			var err error
+			if e, err = parseJSONPathExpression(p.JSONPath); err != nil {
+				return false
+			}
+		}
+		doc, err := decodeJSON(body)
+		if err != nil || !e.eval(doc) {
+			return false
+		}
+	}
+	if p.XPath != "" {
+		e := p.xpath
+		if e == nil {
+			var err error
+			if e, err = parseXPathExpression(p.XPath); err != nil {
+				return false
+			}
+		}
+		doc, err := parseXML(body)
+		if err != nil || !e.eval(doc) {
+			return false
+		}
+	}
+	if p.Base64 != "" {
+		b, err := base64.StdEncoding.DecodeString(p.Base64)
+		if err != nil || string(b) != body {
+			return false
+		}
+	}
+	if p.SHA256 != "" {
+		sum := sha256.Sum256([]byte(body))
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), p.SHA256) {
+			return false
+		}
+	}
+	return true
+}
+
+// formValues parses the given body as a form according to its content type.
+// It returns false when the content type is not a form.
+func formValues(contentType, body string) (url.Values, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(body)
+		return values, err == nil
+	case "multipart/form-data":
+		form, err := multipart.NewReader(strings.NewReader(body), params["boundary"]).ReadForm(int64(len(body)))
+		if err != nil {
+			return nil, false
+		}
+		return form.Value, true
+	}
+	return nil, false
+}
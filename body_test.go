@@ -0,0 +1,214 @@
+package gmock
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_matchJSON(t *testing.T) {
+	tests := []struct {
+		name             string
+		expected         string
+		actual           string
+		partial          bool
+		ignoreArrayOrder bool
+		want             bool
+	}{
+		{
+			name:     "equal",
+			expected: `{"name":"slim","age":30}`,
+			actual:   `{"age":30,"name":"slim"}`,
+			want:     true,
+		},
+		{
+			name:     "equal numbers with different representations",
+			expected: `{"amount":100}`,
+			actual:   `{"amount":100.0}`,
+			want:     true,
+		},
+		{
+			name:     "extra field",
+			expected: `{"name":"slim"}`,
+			actual:   `{"name":"slim","age":30}`,
+			want:     false,
+		},
+		{
+			name:     "extra field with partial",
+			expected: `{"name":"slim"}`,
+			actual:   `{"name":"slim","age":30}`,
+			partial:  true,
+			want:     true,
+		},
+		{
+			name:     "extra nested field with partial",
+			expected: `{"user":{"name":"slim"}}`,
+			actual:   `{"user":{"name":"slim","age":30},"id":1}`,
+			partial:  true,
+			want:     true,
+		},
+		{
+			name:     "missing field with partial",
+			expected: `{"name":"slim","age":30}`,
+			actual:   `{"name":"slim"}`,
+			partial:  true,
+			want:     false,
+		},
+		{
+			name:     "different array order",
+			expected: `{"ids":[1,2,3]}`,
+			actual:   `{"ids":[3,1,2]}`,
+			want:     false,
+		},
+		{
+			name:             "different array order with ignore array order",
+			expected:         `{"ids":[1,2,3]}`,
+			actual:           `{"ids":[3,1,2]}`,
+			ignoreArrayOrder: true,
+			want:             true,
+		},
+		{
+			name:             "different array length with ignore array order",
+			expected:         `{"ids":[1,1]}`,
+			actual:           `{"ids":[1,2]}`,
+			ignoreArrayOrder: true,
+			want:             false,
+		},
+		{
+			name:     "any string",
+			expected: `{"id":"${json-unit.any-string}"}`,
+			actual:   `{"id":"0a5c7d"}`,
+			want:     true,
+		},
+		{
+			name:     "any string with a number",
+			expected: `{"id":"${json-unit.any-string}"}`,
+			actual:   `{"id":1}`,
+			want:     false,
+		},
+		{
+			name:     "any number",
+			expected: `{"id":"${json-unit.any-number}"}`,
+			actual:   `{"id":1.5}`,
+			want:     true,
+		},
+		{
+			name:     "any boolean",
+			expected: `{"active":"${json-unit.any-boolean}"}`,
+			actual:   `{"active":false}`,
+			want:     true,
+		},
+		{
+			name:     "ignore",
+			expected: `{"name":"slim","meta":"${json-unit.ignore}"}`,
+			actual:   `{"name":"slim","meta":{"created":"today"}}`,
+			want:     true,
+		},
+		{
+			name:     "ignore missing field",
+			expected: `{"name":"slim","meta":"${json-unit.ignore}"}`,
+			actual:   `{"name":"slim"}`,
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := decodeJSON(tt.expected)
+			require.NoError(t, err)
+			actual, err := decodeJSON(tt.actual)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, matchJSON(expected, actual, tt.partial, tt.ignoreArrayOrder))
+		})
+	}
+}
+
+func TestBodyPattern_match_binary(t *testing.T) {
+	body := "\x89PNG\r\n\x1a\n"
+	p := &BodyPattern{Base64: "iVBORw0KGgo="}
+	assert.Nil(t, p.Validate())
+	assert.True(t, p.match(body))
+	assert.False(t, p.match("PNG"))
+
+	p = &BodyPattern{SHA256: "4c4b6a3be1314ab86138bef4314dde022e600960d8689a2c8f8631802d20dab6"}
+	assert.True(t, p.match(body))
+	assert.False(t, p.match("PNG"))
+
+	p = &BodyPattern{Base64: "not base64!"}
+	assert.Len(t, p.Validate(), 1)
+}
+
+func TestBodyPattern_match_xml(t *testing.T) {
+	p := &BodyPattern{XPath: "/order/sku = 'ABC'"}
+	assert.Nil(t, p.Validate())
+	assert.True(t, p.match(`<order><sku>ABC</sku></order>`))
+	p.compile()
+	assert.NotNil(t, p.xpath)
+	assert.True(t, p.match(`<order><sku>ABC</sku></order>`))
+	assert.False(t, p.match(`<order><sku>DEF</sku></order>`))
+	assert.False(t, p.match(`{"order":{"sku":"ABC"}}`))
+}
+
+func Test_newRequestBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        requestBody
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        "{ \"a\": 1 }\n",
+			want:        requestBody{raw: "{ \"a\": 1 }\n", json: true, compacted: `{"a":1}`},
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/problem+json",
+			body:        `[1, 2]`,
+			want:        requestBody{raw: `[1, 2]`, json: true, compacted: `[1,2]`},
+		},
+		{
+			name: "json without content type",
+			body: `[1, 2]`,
+			want: requestBody{raw: `[1, 2]`, json: true, compacted: `[1,2]`},
+		},
+		{
+			name:        "json document sent as text",
+			contentType: "text/plain",
+			body:        `[1, 2]`,
+			want:        requestBody{raw: `[1, 2]`, compacted: `[1, 2]`},
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{`,
+			want:        requestBody{raw: `{`, compacted: `{`},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newRequestBody(tt.contentType, []byte(tt.body)))
+		})
+	}
+}
+
+func Test_formValues(t *testing.T) {
+	values, ok := formValues("application/x-www-form-urlencoded", "name=slim&tags=a&tags=b")
+	assert.True(t, ok)
+	assert.Equal(t, url.Values{"name": {"slim"}, "tags": {"a", "b"}}, values)
+
+	body := "--boundary\r\n" +
+		"Content-Disposition: form-data; name=\"name\"\r\n\r\n" +
+		"slim\r\n" +
+		"--boundary--\r\n"
+	values, ok = formValues("multipart/form-data; boundary=boundary", body)
+	assert.True(t, ok)
+	assert.Equal(t, url.Values{"name": {"slim"}}, values)
+
+	_, ok = formValues("application/json", `{"name":"slim"}`)
+	assert.False(t, ok)
+}
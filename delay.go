@@ -0,0 +1,61 @@
+package gmock // nolint:golint
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Delay is the time a response is delayed by, in milliseconds.
+// Fixed delays by a fixed time, Min and Max by a uniformly distributed random time between them
+// and Median and Sigma by a lognormally distributed random time, useful to simulate the long tail of real latencies.
+// When more than one is set, the delays are added up.
+type Delay struct {
+	Fixed  int     `json:"fixed,omitempty" yaml:"fixed,omitempty"`
+	Min    int     `json:"min,omitempty" yaml:"min,omitempty"`
+	Max    int     `json:"max,omitempty" yaml:"max,omitempty"`
+	Median int     `json:"median,omitempty" yaml:"median,omitempty"`
+	Sigma  float64 `json:"sigma,omitempty" yaml:"sigma,omitempty"`
+}
+
+// Validate returns a list of validation errors for the current delay.
+func (d *Delay) Validate() []error {
+	var errs []error
+	if d.Fixed < 0 || d.Min < 0 || d.Max < 0 || d.Median < 0 || d.Sigma < 0 || d.Min > d.Max {
+		errs = append(errs, &ErrInvalidDelay{Delay: *d})
+	}
+	return errs
+}
+
+// duration returns a (random) duration following the delay settings.
+// A nil delay has no duration.
+func (d *Delay) duration() time.Duration {
+	if d == nil {
+		return 0
+	}
+	ms := float64(d.Fixed)
+	if d.Max > 0 {
+		ms += float64(d.Min) + rand.Float64()*float64(d.Max-d.Min) // nolint:gosec
+	}
+	if d.Median > 0 {
+		ms += float64(d.Median) * math.Exp(rand.NormFloat64()*d.Sigma) // nolint:gosec
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// sleep waits for the given duration, unless the context is done first.
+// It returns false when the context is done before the duration elapses.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package gmock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay_duration(t *testing.T) {
+	var d *Delay
+	assert.Equal(t, time.Duration(0), d.duration())
+
+	d = &Delay{Fixed: 100}
+	assert.Equal(t, 100*time.Millisecond, d.duration())
+
+	d = &Delay{Min: 100, Max: 200}
+	for i := 0; i < 100; i++ {
+		got := d.duration()
+		assert.GreaterOrEqual(t, got, 100*time.Millisecond)
+		assert.LessOrEqual(t, got, 200*time.Millisecond)
+	}
+
+	d = &Delay{Fixed: 50, Median: 100}
+	assert.Equal(t, 150*time.Millisecond, d.duration())
+
+	d = &Delay{Median: 100, Sigma: 0.5}
+	for i := 0; i < 100; i++ {
+		assert.Greater(t, d.duration(), time.Duration(0))
+	}
+}
+
+func TestDelay_Validate(t *testing.T) {
+	assert.Nil(t, (&Delay{Fixed: 10, Min: 10, Max: 20, Median: 10, Sigma: 0.1}).Validate())
+	assert.Len(t, (&Delay{Fixed: -1}).Validate(), 1)
+	assert.Len(t, (&Delay{Min: 20, Max: 10}).Validate(), 1)
+}
+
+func Test_sleep(t *testing.T) {
+	assert.True(t, sleep(context.Background(), 0))
+	assert.True(t, sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, sleep(ctx, time.Minute))
+}
+
+func TestServer_genericStubHandler_delay(t *testing.T) {
+	s := NewServer().WithDelay(&Delay{Fixed: 20}).WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/slow"},
+		Response: StubResponse{StatusCode: http.StatusOK, Delay: &Delay{Fixed: 30}},
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	s.genericStubHandler(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	w = httptest.NewRecorder()
+	s.genericStubHandler(w, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Empty(t, w.Body.String())
+}
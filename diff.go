@@ -0,0 +1,201 @@
+package gmock // nolint:golint
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldDiff is the comparison of a field of a stub request with the same field of an incoming request.
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Match    bool   `json:"match"`
+}
+
+// String returns a description of the matcher, e.g. equal to "json" and contains "application".
+func (m Matcher) String() string {
+	if m.Absent {
+		return "absent"
+	}
+	var predicates []string
+	if m.EqualTo != "" {
+		predicates = append(predicates, fmt.Sprintf("equal to %q", m.EqualTo))
+	}
+	if m.Contains != "" {
+		predicates = append(predicates, fmt.Sprintf("contains %q", m.Contains))
+	}
+	if m.Matches != "" {
+		predicates = append(predicates, fmt.Sprintf("matches %q", m.Matches))
+	}
+	if len(predicates) == 0 {
+		return "present"
+	}
+	s := strings.Join(predicates, " and ")
+	if m.CaseInsensitive {
+		s += " (case insensitive)"
+	}
+	return s
+}
+
+// diff compares every field of the stub request with the incoming request and its body.
+// Fields left empty in the stub request are not compared.
+func (r *StubRequest) diff(req *http.Request, body requestBody) []FieldDiff {
+	var diffs []FieldDiff
+	add := func(field, expected, actual string, match bool) {
+		diffs = append(diffs, FieldDiff{Field: field, Expected: expected, Actual: actual, Match: match})
+	}
+
+	if r.Method != "" {
+		add("method", r.Method, req.Method, r.Method == req.Method)
+	}
+	_, _, ok := r.matchPath(req.URL.Path)
+	if r.PathPattern != "" {
+		add("path", r.PathPattern, req.URL.Path, ok)
+	} else {
+		add("path", r.Path, req.URL.Path, ok)
+	}
+
+	query := req.URL.Query()
+	for _, k := range sortedKeys(r.Query) {
+		add("query "+k, strings.Join(r.Query[k], ", "), strings.Join(query[k], ", "), reflect.DeepEqual(r.Query[k], query[k]))
+	}
+	for _, k := range sortedKeys(r.QueryParams) {
+		m := r.QueryParams[k]
+		add("query "+k, m.String(), strings.Join(query[k], ", "), m.match(query[k]))
+	}
+	for _, k := range sortedKeys(r.Headers) {
+		m := r.Headers[k]
+		values := req.Header.Values(k)
+		add("header "+k, m.String(), strings.Join(values, ", "), m.match(values))
+	}
+
+	if r.Body != nil {
+		expected, _ := compactJSON(r.Body)
+		if s, ok := r.Body.(string); ok {
+			expected = s
+		}
+		add("body", expected, body.compacted, r.matchBody(body))
+	}
+	if r.BodyMatcher != nil {
+		var values []string
+		if body.raw != "" {
+			values = []string{body.raw}
+		}
+		add("body", r.BodyMatcher.String(), body.raw, r.BodyMatcher.match(values))
+	}
+	if len(r.FormParams) > 0 {
+		form, _ := formValues(req.Header.Get("Content-Type"), body.raw)
+		for _, k := range sortedKeys(r.FormParams) {
+			m := r.FormParams[k]
+			add("form "+k, m.String(), strings.Join(form[k], ", "), m.match(form[k]))
+		}
+	}
+	for _, p := range r.BodyPatterns {
+		add("body", p.String(), body.raw, p.match(body.raw))
+	}
+	return diffs
+}
+
+// String returns a description of the body pattern.
+func (p BodyPattern) String() string {
+	var predicates []string
+	if p.JSONPath != "" {
+		predicates = append(predicates, "json path "+p.JSONPath)
+	}
+	if p.XPath != "" {
+		predicates = append(predicates, "xpath "+p.XPath)
+	}
+	if p.Base64 != "" {
+		predicates = append(predicates, "base64 "+p.Base64)
+	}
+	if p.SHA256 != "" {
+		predicates = append(predicates, "sha256 "+p.SHA256)
+	}
+	return strings.Join(predicates, " and ")
+}
+
+// distance returns how far the compared fields are from matching, from 0 (every field matches) to 1 (none does).
+// Mismatching fields that are compared for equality count by how different their values are.
+func distance(diffs []FieldDiff) float64 {
+	if len(diffs) == 0 {
+		return 0
+	}
+	var d float64
+	for _, diff := range diffs {
+		switch {
+		case diff.Match:
+		case diff.Field == "method" || diff.Field == "path":
+			d += levenshteinRatio(diff.Expected, diff.Actual)
+		default:
+			d++
+		}
+	}
+	return d / float64(len(diffs))
+}
+
+// levenshteinRatio returns the Levenshtein distance between a and b relative to the length of the longest,
+// from 0 (equal) to 1 (nothing in common).
+func levenshteinRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return float64(prev[len(rb)]) / float64(longest)
+}
+
+// min3 returns the minimum of three integers.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// formatDiff returns a human-readable representation of the mismatching fields, one per line.
+func formatDiff(diffs []FieldDiff, indent string) string {
+	var b strings.Builder
+	for _, diff := range diffs {
+		if diff.Match {
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s: expected %s but was %q\n", indent, diff.Field, diff.Expected, diff.Actual)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package gmock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		want    string
+	}{
+		{name: "present", matcher: Matcher{}, want: "present"},
+		{name: "absent", matcher: Matcher{Absent: true}, want: "absent"},
+		{name: "equal to", matcher: Matcher{EqualTo: "json"}, want: `equal to "json"`},
+		{
+			name:    "several predicates",
+			matcher: Matcher{Contains: "app", Matches: "^app.*", CaseInsensitive: true},
+			want:    `contains "app" and matches "^app.*" (case insensitive)`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.matcher.String())
+		})
+	}
+}
+
+func TestStubRequest_diff(t *testing.T) {
+	r := &StubRequest{
+		Method:  http.MethodPost,
+		Path:    "/v1/users",
+		Headers: map[string]Matcher{"Content-Type": {Contains: "json"}},
+		Body:    map[string]any{"name": "slim"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(`{"name":"shady"}`))
+	req.Header.Set("Content-Type", "application/json")
+	diffs := r.diff(req, newRequestBody("application/json", []byte(`{"name":"shady"}`)))
+
+	assert.Equal(t, []FieldDiff{
+		{Field: "method", Expected: "POST", Actual: "POST", Match: true},
+		{Field: "path", Expected: "/v1/users", Actual: "/v1/user", Match: false},
+		{Field: "header Content-Type", Expected: `contains "json"`, Actual: "application/json", Match: true},
+		{Field: "body", Expected: `{"name":"slim"}`, Actual: `{"name":"shady"}`, Match: false},
+	}, diffs)
+	assert.Equal(t, "  path: expected /v1/users but was \"/v1/user\"\n  body: expected {\"name\":\"slim\"} but was \"{\\\"name\\\":\\\"shady\\\"}\"\n", formatDiff(diffs, "  "))
+}
+
+func Test_distance(t *testing.T) {
+	assert.Equal(t, 0.0, distance(nil))
+	assert.Equal(t, 0.0, distance([]FieldDiff{{Field: "method", Match: true}}))
+	assert.Equal(t, 0.5, distance([]FieldDiff{{Field: "method", Match: true}, {Field: "body", Match: false}}))
+
+	near := distance([]FieldDiff{{Field: "path", Expected: "/v1/users", Actual: "/v1/user"}})
+	far := distance([]FieldDiff{{Field: "path", Expected: "/v1/users", Actual: "/health"}})
+	assert.Less(t, near, far)
+}
+
+func Test_levenshteinRatio(t *testing.T) {
+	assert.Equal(t, 0.0, levenshteinRatio("", ""))
+	assert.Equal(t, 0.0, levenshteinRatio("kitten", "kitten"))
+	assert.InDelta(t, 3.0/7.0, levenshteinRatio("kitten", "sitting"), 1e-9)
+	assert.Equal(t, 1.0, levenshteinRatio("abc", ""))
+}
@@ -1,31 +1,509 @@
 package gmock // nolint:golint
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
-type errRequiredMethod struct{}
+// FieldError is an error about a field of a stub, e.g. request.method or responses[1].status_code.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
 
-func (*errRequiredMethod) Error() string {
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrors returns the given errors as errors about the given field,
+// the field of errors already about a field is nested in it (e.g. request and method make request.method).
+func fieldErrors(field string, errs []error) []error {
+	for i, err := range errs {
+		if fe, ok := err.(*FieldError); ok {
+			errs[i] = &FieldError{Field: field + "." + fe.Field, Err: fe.Err}
+			continue
+		}
+		errs[i] = &FieldError{Field: field, Err: err}
+	}
+	return errs
+}
+
+// ErrRequiredMethod is returned when a stub request has no method.
+type ErrRequiredMethod struct{}
+
+func (*ErrRequiredMethod) Error() string {
 	return "method is required"
 }
 
-type errInvalidMethod struct {
-	method string
+// Code returns the code of the error.
+func (*ErrRequiredMethod) Code() string {
+	return "required_method"
+}
+
+// ErrInvalidMethod is returned when a stub request has an unknown method.
+type ErrInvalidMethod struct {
+	Method string
+}
+
+func (e *ErrInvalidMethod) Error() string {
+	return fmt.Sprintf("method %s is not valid", e.Method)
 }
 
-func (e *errInvalidMethod) Error() string {
-	return fmt.Sprintf("method %s is not valid", e.method)
+// Code returns the code of the error.
+func (*ErrInvalidMethod) Code() string {
+	return "invalid_method"
 }
 
-type errRequiredPath struct{}
+// ErrRequiredPath is returned when a stub request has neither a path nor a path pattern.
+type ErrRequiredPath struct{}
 
-func (*errRequiredPath) Error() string {
+func (*ErrRequiredPath) Error() string {
 	return "path is required"
 }
 
-type errInvalidStatusCode struct {
-	statusCode int
+// Code returns the code of the error.
+func (*ErrRequiredPath) Code() string {
+	return "required_path"
+}
+
+// ErrInvalidStatusCode is returned when a stub response has a status code outside of 200-599.
+type ErrInvalidStatusCode struct {
+	StatusCode int
+}
+
+func (e *ErrInvalidStatusCode) Error() string {
+	return fmt.Sprintf("status code %d is not valid", e.StatusCode)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidStatusCode) Code() string {
+	return "invalid_status_code"
+}
+
+// ErrInvalidBodyMode is returned when a stub response has an unknown body mode,
+// or a raw or base64 body mode with a body that isn't a string.
+type ErrInvalidBodyMode struct {
+	BodyMode BodyMode
+}
+
+func (e *ErrInvalidBodyMode) Error() string {
+	return fmt.Sprintf("body mode %s is not valid", e.BodyMode)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidBodyMode) Code() string {
+	return "invalid_body_mode"
+}
+
+// ErrInvalidBase64Body is returned when a stub response in base64 body mode has a body that isn't valid base64.
+type ErrInvalidBase64Body struct {
+	Err error
+}
+
+func (e *ErrInvalidBase64Body) Error() string {
+	return fmt.Sprintf("body is not valid base64: %v", e.Err)
+}
+
+func (e *ErrInvalidBase64Body) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidBase64Body) Code() string {
+	return "invalid_base64_body"
+}
+
+// ErrInvalidBodyFile is returned when the body file of a stub response does not exist.
+type ErrInvalidBodyFile struct {
+	BodyFile string
+}
+
+func (e *ErrInvalidBodyFile) Error() string {
+	return fmt.Sprintf("body file %s does not exist", e.BodyFile)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidBodyFile) Code() string {
+	return "invalid_body_file"
+}
+
+// ErrUnsafeBodyFile is returned when a stub added through the admin API has a body file
+// that is absolute or outside of the files directory of the server.
+type ErrUnsafeBodyFile struct {
+	BodyFile string
+}
+
+func (e *ErrUnsafeBodyFile) Error() string {
+	return fmt.Sprintf("body file %s must be a relative path inside the files directory", e.BodyFile)
+}
+
+// Code returns the code of the error.
+func (*ErrUnsafeBodyFile) Code() string {
+	return "unsafe_body_file"
+}
+
+// ErrConflictingBody is returned when a stub response has both a body and a body file.
+type ErrConflictingBody struct{}
+
+func (*ErrConflictingBody) Error() string {
+	return "body and body file can't be both set"
+}
+
+// Code returns the code of the error.
+func (*ErrConflictingBody) Code() string {
+	return "conflicting_body"
+}
+
+// ErrInvalidFault is returned when a stub response has an unknown fault.
+type ErrInvalidFault struct {
+	Fault Fault
+}
+
+func (e *ErrInvalidFault) Error() string {
+	return fmt.Sprintf("fault %s is not valid", e.Fault)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidFault) Code() string {
+	return "invalid_fault"
+}
+
+// ErrInvalidExhaustedPolicy is returned when a stub has an unknown after exhausted policy.
+type ErrInvalidExhaustedPolicy struct {
+	Policy ExhaustedPolicy
+}
+
+func (e *ErrInvalidExhaustedPolicy) Error() string {
+	return fmt.Sprintf("after exhausted policy %s is not valid", e.Policy)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidExhaustedPolicy) Code() string {
+	return "invalid_exhausted_policy"
+}
+
+// ErrRequiredScenario is returned when a stub has a required or new state but no scenario.
+type ErrRequiredScenario struct{}
+
+func (*ErrRequiredScenario) Error() string {
+	return "scenario is required when setting a required or new state"
+}
+
+// Code returns the code of the error.
+func (*ErrRequiredScenario) Code() string {
+	return "required_scenario"
+}
+
+// ErrInvalidPattern is returned when a path template or a regular expression doesn't compile.
+type ErrInvalidPattern struct {
+	Pattern string
+	Err     error
+}
+
+func (e *ErrInvalidPattern) Error() string {
+	return fmt.Sprintf("pattern %s is not valid: %v", e.Pattern, e.Err)
+}
+
+func (e *ErrInvalidPattern) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidPattern) Code() string {
+	return "invalid_pattern"
+}
+
+// ErrInvalidExpression is returned when a JSONPath, XPath or base64 body pattern can't be parsed.
+type ErrInvalidExpression struct {
+	Expression string
+	Err        error
+}
+
+func (e *ErrInvalidExpression) Error() string {
+	return fmt.Sprintf("expression %s is not valid: %v", e.Expression, e.Err)
+}
+
+func (e *ErrInvalidExpression) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidExpression) Code() string {
+	return "invalid_expression"
+}
+
+// ErrInvalidTemplate is returned when a template of a stub response can't be parsed.
+type ErrInvalidTemplate struct {
+	Template string
+	Err      error
+}
+
+func (e *ErrInvalidTemplate) Error() string {
+	return fmt.Sprintf("template %s is not valid: %v", e.Template, e.Err)
+}
+
+func (e *ErrInvalidTemplate) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidTemplate) Code() string {
+	return "invalid_template"
+}
+
+// ErrInvalidDelay is returned when a delay has negative values or a minimum greater than its maximum.
+type ErrInvalidDelay struct {
+	Delay Delay
+}
+
+func (e *ErrInvalidDelay) Error() string {
+	return fmt.Sprintf("delay %+v is not valid", e.Delay)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidDelay) Code() string {
+	return "invalid_delay"
+}
+
+// ErrInvalidStub is returned when a stub is not valid, along with its validation errors.
+type ErrInvalidStub struct {
+	Errs []error
+}
+
+func (e *ErrInvalidStub) Error() string {
+	return fmt.Sprintf("stub is not valid: %v", e.Errs)
+}
+
+func (e *ErrInvalidStub) Unwrap() []error {
+	return e.Errs
+}
+
+// As finds the first validation error matching target, for Go versions that don't unwrap multiple errors.
+func (e *ErrInvalidStub) As(target any) bool {
+	return asAny(e.Errs, target)
+}
+
+// Is reports whether any validation error matches target, for Go versions that don't unwrap multiple errors.
+func (e *ErrInvalidStub) Is(target error) bool {
+	return isAny(e.Errs, target)
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidStub) Code() string {
+	return "invalid_stub"
+}
+
+// ErrStubNotFound is returned when there's no stub with the given id.
+type ErrStubNotFound struct {
+	ID string
+}
+
+func (e *ErrStubNotFound) Error() string {
+	return fmt.Sprintf("stub %s not found", e.ID)
+}
+
+// Code returns the code of the error.
+func (*ErrStubNotFound) Code() string {
+	return "stub_not_found"
+}
+
+// ErrStoreStub is returned when a valid stub can't be added to the stub store of the server
+// (e.g. when a FileStubStore fails to write its file).
+type ErrStoreStub struct {
+	ID  string
+	Err error
+}
+
+func (e *ErrStoreStub) Error() string {
+	return fmt.Sprintf("error storing stub %s: %v", e.ID, e.Err)
+}
+
+func (e *ErrStoreStub) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrStoreStub) Code() string {
+	return "store_error"
+}
+
+// ErrInvalidBody is returned when the body of a request to the admin API can't be parsed.
+type ErrInvalidBody struct {
+	Err error
+}
+
+func (e *ErrInvalidBody) Error() string {
+	return fmt.Sprintf("body is not valid: %v", e.Err)
+}
+
+func (e *ErrInvalidBody) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the code of the error.
+func (*ErrInvalidBody) Code() string {
+	return "invalid_body"
+}
+
+// ErrEmptyStub is returned when a stub file or the body of a request to the admin API is empty.
+type ErrEmptyStub struct{}
+
+func (*ErrEmptyStub) Error() string {
+	return "stub is empty"
+}
+
+// Code returns the code of the error.
+func (*ErrEmptyStub) Code() string {
+	return "empty_stub"
+}
+
+// ErrMethodNotAllowed is returned when an endpoint of the admin API doesn't support the method of a request.
+type ErrMethodNotAllowed struct {
+	Method string
+}
+
+func (e *ErrMethodNotAllowed) Error() string {
+	return fmt.Sprintf("method %s not allowed", e.Method)
+}
+
+// Code returns the code of the error.
+func (*ErrMethodNotAllowed) Code() string {
+	return "method_not_allowed"
+}
+
+// ErrRequiredState is returned when setting the state of a scenario without a state.
+type ErrRequiredState struct{}
+
+func (*ErrRequiredState) Error() string {
+	return "state is required"
+}
+
+// Code returns the code of the error.
+func (*ErrRequiredState) Code() string {
+	return "required_state"
+}
+
+// ErrorDetail is an error of an ErrorDocument.
+// Field is the field the error is about, if any (e.g. request.method),
+// and Code identifies the kind of error (e.g. required_method).
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorDocument is the JSON body of the error responses of the admin API.
+type ErrorDocument struct {
+	Errors []ErrorDetail `json:"errors"`
+}
+
+// newErrorDocument returns the error document of the given errors,
+// where the validation errors of invalid stubs are listed one by one.
+func newErrorDocument(errs []error) ErrorDocument {
+	doc := ErrorDocument{Errors: make([]ErrorDetail, 0, len(errs))}
+	for _, err := range errs {
+		var invalid *ErrInvalidStub
+		if errors.As(err, &invalid) {
+			doc.Errors = append(doc.Errors, newErrorDocument(invalid.Errs).Errors...)
+			continue
+		}
+		detail := ErrorDetail{Code: "invalid", Message: err.Error()}
+		var fe *FieldError
+		if errors.As(err, &fe) {
+			detail.Field = fe.Field
+			detail.Message = fe.Err.Error()
+		}
+		var coded interface{ Code() string }
+		if errors.As(err, &coded) {
+			detail.Code = coded.Code()
+		}
+		doc.Errors = append(doc.Errors, detail)
+	}
+	return doc
+}
+
+// StubError is the error of a stub that can't be loaded or added,
+// along with the file (and the line and column in it) the stub comes from, if any.
+type StubError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *StubError) Error() string {
+	switch {
+	case e.File == "":
+		return e.Err.Error()
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+	}
+}
+
+func (e *StubError) Unwrap() error {
+	return e.Err
+}
+
+// ErrStubs is returned when stubs can't be loaded or added, along with every error.
+type ErrStubs struct {
+	Errs []error
+}
+
+func (e *ErrStubs) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d stub errors:\n%s", len(e.Errs), strings.Join(msgs, "\n"))
+}
+
+func (e *ErrStubs) Unwrap() []error {
+	return e.Errs
+}
+
+// As finds the first error matching target, for Go versions that don't unwrap multiple errors.
+func (e *ErrStubs) As(target any) bool {
+	return asAny(e.Errs, target)
+}
+
+// Is reports whether any error matches target, for Go versions that don't unwrap multiple errors.
+func (e *ErrStubs) Is(target error) bool {
+	return isAny(e.Errs, target)
+}
+
+// asAny finds the first of the given errors matching target, see errors.As.
+func asAny(errs []error, target any) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAny reports whether any of the given errors matches target, see errors.Is.
+func isAny(errs []error, target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
-func (e *errInvalidStatusCode) Error() string {
-	return fmt.Sprintf("status code %d is not valid", e.statusCode)
+// stubsError returns the given errors as an ErrStubs, or nil when there are none.
+func stubsError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ErrStubs{Errs: errs}
 }
@@ -0,0 +1,61 @@
+package gmock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_fieldErrors(t *testing.T) {
+	errs := fieldErrors("request", []error{
+		&ErrRequiredPath{},
+		&FieldError{Field: "method", Err: &ErrRequiredMethod{}},
+	})
+	assert.Equal(t, []error{
+		&FieldError{Field: "request", Err: &ErrRequiredPath{}},
+		&FieldError{Field: "request.method", Err: &ErrRequiredMethod{}},
+	}, errs)
+	assert.EqualError(t, errs[1], "request.method: method is required")
+
+	var required *ErrRequiredMethod
+	assert.True(t, errors.As(errs[1], &required))
+}
+
+func Test_newErrorDocument(t *testing.T) {
+	stub := &Stub{
+		Request:  StubRequest{Method: "FETCH", Headers: map[string]Matcher{"Accept": {Matches: "("}}},
+		Response: StubResponse{StatusCode: 99},
+	}
+	doc := newErrorDocument([]error{&ErrInvalidStub{Errs: stub.validationErrors()}, errors.New("boom")})
+	assert.Equal(t, ErrorDocument{Errors: []ErrorDetail{
+		{Field: "request.method", Code: "invalid_method", Message: "method FETCH is not valid"},
+		{Field: "request.path", Code: "required_path", Message: "path is required"},
+		{
+			Field:   "request.headers.Accept",
+			Code:    "invalid_pattern",
+			Message: "pattern ( is not valid: error parsing regexp: missing closing ): `(`",
+		},
+		{Field: "response.status_code", Code: "invalid_status_code", Message: "status code 99 is not valid"},
+		{Code: "invalid", Message: "boom"},
+	}}, doc)
+}
+
+func TestErrStubs_As(t *testing.T) {
+	err := &ErrStubs{Errs: []error{
+		&StubError{File: "users.json", Err: &ErrEmptyStub{}},
+		&ErrInvalidStub{Errs: []error{&FieldError{Field: "request.method", Err: &ErrRequiredMethod{}}}},
+	}}
+
+	// called directly, as errors.As and errors.Is only unwrap multiple errors since Go 1.20
+	var required *ErrRequiredMethod
+	assert.True(t, err.As(&required))
+	var stubErr *StubError
+	assert.True(t, err.As(&stubErr))
+	assert.Equal(t, "users.json", stubErr.File)
+	var notFound *ErrStubNotFound
+	assert.False(t, err.As(&notFound))
+
+	assert.True(t, err.Is(stubErr))
+	assert.False(t, err.Is(errors.New("boom")))
+}
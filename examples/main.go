@@ -44,7 +44,9 @@ func main() {
 		Port:  8888,
 		Stubs: []*gmock.Stub{stub, stub},
 	})
-	sv.Start()
+	if err := sv.Start(); err != nil {
+		panic(err)
+	}
 
 	time.Sleep(60 * time.Second)
 	if err := sv.Stop(); err != nil {
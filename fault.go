@@ -0,0 +1,94 @@
+package gmock // nolint:golint
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Fault is a broken response written instead of a regular stub response, to test client resilience.
+type Fault string
+
+const (
+	// FaultConnectionReset closes the connection with a TCP reset ("connection reset by peer").
+	FaultConnectionReset Fault = "connection_reset"
+	// FaultEmptyResponse closes the connection without writing anything.
+	FaultEmptyResponse Fault = "empty_response"
+	// FaultMalformedChunk writes a chunked response with an invalid chunk and closes the connection.
+	FaultMalformedChunk Fault = "malformed_chunk"
+	// FaultRandomDataThenClose writes random garbage and closes the connection.
+	FaultRandomDataThenClose Fault = "random_data_then_close"
+	// FaultPartialBody writes the headers and only half of the body and closes the connection.
+	FaultPartialBody Fault = "partial_body"
+)
+
+// faults is the set of valid faults.
+var faults = map[Fault]struct{}{
+	FaultConnectionReset:     {},
+	FaultEmptyResponse:       {},
+	FaultMalformedChunk:      {},
+	FaultRandomDataThenClose: {},
+	FaultPartialBody:         {},
+}
+
+// writeFault hijacks the connection of the response writer to write the fault of the stub response.
+func (r *StubResponse) writeFault(w http.ResponseWriter, in *incomingRequest) error {
+	var body []byte
+	if r.Fault == FaultPartialBody {
+		var err error
+		if body, err = r.bodyBytes(in); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return fmt.Errorf("error encoding stub body: %w", err)
+		}
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("fault %s is not supported by the connection", r.Fault)
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return fmt.Errorf("error hijacking connection: %w", err)
+	}
+	defer conn.Close()
+
+	switch r.Fault {
+	case FaultConnectionReset:
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			// discard unsent data and send a RST instead of a FIN when closing
+			if err := tcp.SetLinger(0); err != nil {
+				return fmt.Errorf("error resetting connection: %w", err)
+			}
+		}
+		return nil
+	case FaultEmptyResponse:
+		return nil
+	case FaultMalformedChunk:
+		fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", r.StatusCode, http.StatusText(r.StatusCode))
+		fmt.Fprint(buf, "Transfer-Encoding: chunked\r\n\r\n")
+		fmt.Fprint(buf, "lorem ipsum dolor sit amet\r\n")
+	case FaultRandomDataThenClose:
+		garbage := make([]byte, 1024)
+		if _, err := rand.Read(garbage); err != nil {
+			return err
+		}
+		if _, err := buf.Write(garbage); err != nil {
+			return err
+		}
+	case FaultPartialBody:
+		if _, ok := w.Header()["Content-Type"]; !ok {
+			w.Header().Set("Content-Type", r.bodyMode().contentType())
+		}
+		fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", r.StatusCode, http.StatusText(r.StatusCode))
+		if err := w.Header().Write(buf); err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "Content-Length: %d\r\n\r\n", len(body))
+		if _, err := buf.Write(body[:len(body)/2]); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
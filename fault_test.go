@@ -0,0 +1,55 @@
+package gmock
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStubResponse_writeFault(t *testing.T) {
+	tests := []struct {
+		fault Fault
+	}{
+		{fault: FaultConnectionReset},
+		{fault: FaultEmptyResponse},
+		{fault: FaultMalformedChunk},
+		{fault: FaultRandomDataThenClose},
+		{fault: FaultPartialBody},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.fault), func(t *testing.T) {
+			s := NewServer().WithStubs(&Stub{
+				Request:  StubRequest{Method: http.MethodGet, Path: "/fault"},
+				Response: StubResponse{StatusCode: http.StatusOK, Body: map[string]any{"message": "this body is long enough to be cut in half"}, Fault: tt.fault},
+			})
+			srv := httptest.NewServer(http.HandlerFunc(s.genericStubHandler))
+			defer srv.Close()
+
+			res, err := srv.Client().Get(srv.URL + "/fault")
+			if err == nil {
+				_, err = io.ReadAll(res.Body)
+				res.Body.Close()
+			}
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStubResponse_writeFault_unsupported(t *testing.T) {
+	r := &StubResponse{StatusCode: http.StatusOK, Fault: FaultEmptyResponse}
+	w := httptest.NewRecorder()
+	assert.Error(t, r.write(w, &incomingRequest{}))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestStubResponse_Validate_fault(t *testing.T) {
+	r := &StubResponse{StatusCode: http.StatusOK, Fault: FaultConnectionReset}
+	assert.Nil(t, r.Validate())
+
+	r.Fault = "unknown"
+	assert.Len(t, r.Validate(), 1)
+}
@@ -0,0 +1,174 @@
+package gmock // nolint:golint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StubFormat is the format of the stub files written by a FileStubStore.
+type StubFormat string
+
+const (
+	// StubFormatJSON writes stubs as JSON files.
+	StubFormatJSON StubFormat = "json"
+	// StubFormatYAML writes stubs as YAML files.
+	StubFormatYAML StubFormat = "yaml"
+)
+
+// FileStubStore is a StubStore keeping stubs in memory and persisting them as files of a directory,
+// named after their ids (e.g. <id>.json), so that a server using the directory as its stubs directory
+// loads them again on restart. Stubs are written with their request body as received,
+// and generated ids are marked as such so that adding the same stub again after a restart still replaces it.
+// Stubs loaded from files are not written again, and only the files written by the store are removed along with their stubs.
+type FileStubStore struct {
+	mu     sync.Mutex // serializes the changes so that files follow the order of the stubs in memory
+	memory *MemoryStubStore
+	dir    string
+	format StubFormat
+}
+
+// NewFileStubStore creates an empty stub store persisting stubs in the given directory and format.
+func NewFileStubStore(dir string, format StubFormat) *FileStubStore {
+	if format != StubFormatYAML {
+		format = StubFormatJSON
+	}
+	return &FileStubStore{memory: NewMemoryStubStore(), dir: dir, format: format}
+}
+
+// Add writes the file of the stub and adds it to the store,
+// replacing the stubs with the same id (or request, see MemoryStubStore.Add), which are returned and their files removed.
+func (st *FileStubStore) Add(stub *Stub) ([]*Stub, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if stub.source == "" {
+		if err := st.write(stub); err != nil {
+			return nil, err
+		}
+	}
+	replaced, err := st.memory.Add(stub)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range replaced {
+		if existing.ID == stub.ID {
+			continue
+		}
+		if err := st.remove(existing); err != nil {
+			return replaced, err
+		}
+	}
+	return replaced, nil
+}
+
+// Remove removes the stub with the given id from the store, along with its file.
+func (st *FileStubStore) Remove(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stub, ok := st.memory.Get(id)
+	if !ok {
+		return &ErrStubNotFound{ID: id}
+	}
+	if err := st.memory.Remove(id); err != nil {
+		return err
+	}
+	return st.remove(stub)
+}
+
+// Get returns the stub with the given id.
+func (st *FileStubStore) Get(id string) (*Stub, bool) {
+	return st.memory.Get(id)
+}
+
+// List returns a snapshot of the stubs of the store, which must not be modified.
+func (st *FileStubStore) List() []*Stub {
+	return st.memory.List()
+}
+
+// Match returns the stub of the store that best matches the given request and its (compacted) body.
+func (st *FileStubStore) Match(r *http.Request, body string, state func(string) string) (*Stub, map[string]string, bool) {
+	return st.memory.Match(r, body, state)
+}
+
+// Clear removes every stub from the store, along with their files.
+func (st *FileStubStore) Clear() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stubs := st.memory.List()
+	if err := st.memory.Clear(); err != nil {
+		return err
+	}
+	var errs []error
+	for _, stub := range stubs {
+		if err := st.remove(stub); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error removing stub files: %v", errs)
+	}
+	return nil
+}
+
+// path returns the path of the file of the stub with the given id.
+func (st *FileStubStore) path(id string) string {
+	return filepath.Join(st.dir, fmt.Sprintf("%s.%s", id, st.format))
+}
+
+// storedStub is the document of a stub file written by a FileStubStore.
+type storedStub struct {
+	Stub        `yaml:",inline"`
+	GeneratedID bool `json:"generated_id,omitempty" yaml:"generated_id,omitempty"`
+}
+
+// generatedID returns whether the given stub document was written by a FileStubStore for a stub with a generated id.
+func generatedID(node *yaml.Node) bool {
+	var doc struct {
+		GeneratedID bool `yaml:"generated_id"`
+	}
+	return node.Decode(&doc) == nil && doc.GeneratedID
+}
+
+// write writes the file of the stub, with its request body as received.
+func (st *FileStubStore) write(stub *Stub) error {
+	doc := storedStub{Stub: *stub, GeneratedID: stub.generatedID}
+	if stub.receivedBody != nil {
+		doc.Request.Body = stub.receivedBody
+	}
+	var b []byte
+	var err error
+	if st.format == StubFormatYAML {
+		b, err = yaml.Marshal(&doc)
+	} else {
+		b, err = json.MarshalIndent(&doc, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding stub %s: %w", stub.ID, err)
+	}
+	if err := os.MkdirAll(st.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating stubs directory: %w", err)
+	}
+	if err := os.WriteFile(st.path(stub.ID), b, 0o644); err != nil { // nolint:gosec
+		return fmt.Errorf("error writing stub %s: %w", stub.ID, err)
+	}
+	return nil
+}
+
+// remove removes the file of the stub, if it was written by the store.
+func (st *FileStubStore) remove(stub *Stub) error {
+	path := st.path(stub.ID)
+	if stub.source != "" && filepath.Clean(stub.source) != path {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stub %s: %w", stub.ID, err)
+	}
+	return nil
+}
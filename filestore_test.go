@@ -0,0 +1,100 @@
+package gmock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStubStore(t *testing.T) {
+	for _, format := range []StubFormat{StubFormatJSON, StubFormatYAML} {
+		format := format
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			// hand-written stubs are loaded but never written or removed by the store
+			handWritten := filepath.Join(dir, "health.yaml")
+			require.NoError(t, os.WriteFile(handWritten, []byte("request:\n  method: GET\n  path: /health\nresponse:\n  status_code: 200\n"), 0o600))
+
+			s := NewServer().WithStubStore(NewFileStubStore(dir, format)).WithStubsFrom(dir)
+			s.AddStub(&Stub{
+				ID:       "create-user",
+				Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users", Body: map[string]any{"name": "slim"}},
+				Response: StubResponse{StatusCode: http.StatusCreated, Body: map[string]any{"id": 1}},
+			})
+			s.AddStub(&Stub{
+				ID:       "delete-user",
+				Request:  StubRequest{Method: http.MethodDelete, Path: "/v1/users/{id}"},
+				Response: StubResponse{StatusCode: http.StatusNoContent},
+			})
+			assert.FileExists(t, filepath.Join(dir, "create-user."+string(format)))
+			assert.FileExists(t, filepath.Join(dir, "delete-user."+string(format)))
+			require.NoError(t, s.stubs.Remove("delete-user"))
+			assert.NoFileExists(t, filepath.Join(dir, "delete-user."+string(format)))
+
+			// restart
+			s = NewServer().WithStubStore(NewFileStubStore(dir, format)).WithStubsFrom(dir)
+			require.Len(t, s.stubs.List(), 2)
+			stub, ok := s.stubs.Get("create-user")
+			require.True(t, ok)
+			w := httptest.NewRecorder()
+			s.genericStubHandler(w, httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": "slim"}`)))
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.JSONEq(t, `{"id":1}`, w.Body.String())
+			assert.Equal(t, filepath.Join(dir, "create-user."+string(format)), stub.source)
+
+			s.ClearStubs()
+			assert.Empty(t, s.stubs.List())
+			assert.NoFileExists(t, filepath.Join(dir, "create-user."+string(format)))
+			assert.FileExists(t, handWritten)
+		})
+	}
+}
+
+func TestFileStubStore_restart(t *testing.T) {
+	for _, format := range []StubFormat{StubFormatJSON, StubFormatYAML} {
+		format := format
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			newStub := func() *Stub {
+				return &Stub{
+					Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users", Body: map[string]any{"name": "slim", "age": 42}},
+					Response: StubResponse{StatusCode: http.StatusCreated},
+				}
+			}
+			s := NewServer().WithStubStore(NewFileStubStore(dir, format)).WithStubsFrom(dir)
+			s.AddStub(newStub())
+			files, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			b, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+			require.NoError(t, err)
+			assert.NotContains(t, string(b), `\"name\"`)
+
+			// restart and seed the same stub again
+			s = NewServer().WithStubStore(NewFileStubStore(dir, format)).WithStubsFrom(dir)
+			require.Len(t, s.stubs.List(), 1)
+			assert.True(t, s.stubs.List()[0].generatedID)
+			w := httptest.NewRecorder()
+			s.genericStubHandler(w, httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"age": 42, "name": "slim"}`)))
+			assert.Equal(t, http.StatusCreated, w.Code)
+
+			s.AddStub(newStub())
+			assert.Len(t, s.stubs.List(), 1)
+			files, err = os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+func TestFileStubStore_Remove(t *testing.T) {
+	st := NewFileStubStore(t.TempDir(), StubFormatJSON)
+	var notFound *ErrStubNotFound
+	assert.ErrorAs(t, st.Remove("unknown"), &notFound)
+}
@@ -1,45 +1,163 @@
 package gmock // nolint:golint
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
 )
 
 // addStubHandler is the handler for the /httpmock/add endpoint.
 // It expects a POST request with a JSON or YAML body containing a StubRequest.
-// The stub is added to the server's stubs map.
-// If the stub is invalid, it returns a 400 Bad Request.
-// If the stub is valid, it returns a 201 Created.
+// The stub is added to the server's stubs.
+// If the stub is invalid, or has a body file that is absolute or outside of the files directory,
+// it returns a 400 Bad Request, and if the stub store fails to store it, a 500 Internal Server Error.
+// If the stub is valid, it returns a 201 Created with a JSON body containing the id of the stub.
 func (s *Server) addStubHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		log.Error().Msgf("method %s not allowed", r.Method)
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Error().Msgf("error reading body: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.log().Error().Msgf("error reading body: %v", err)
+		s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
 		return
 	}
 
 	stub, err := getStubFromBytes(body)
 	if err != nil {
-		log.Error().Msgf("failed to parse stub request: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		s.log().Error().Msgf("failed to parse stub request: %v", err)
+		s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
+		return
+	}
+	if stub == nil {
+		s.log().Error().Msgf("empty stub request")
+		s.writeErrors(w, http.StatusBadRequest, &ErrEmptyStub{})
+		return
+	}
+	if errs := stub.bodyFileErrors(); len(errs) > 0 {
+		s.log().Error().Msgf("invalid stub: %v", errs)
+		s.writeErrors(w, http.StatusBadRequest, errs...)
 		return
 	}
 
-	if errs := s.addStub(stub); len(errs) > 0 {
-		w.WriteHeader(http.StatusBadRequest)
+	errs, err := s.addStub(stub)
+	if len(errs) > 0 {
+		s.writeErrors(w, http.StatusBadRequest, errs...)
 		return
 	}
+	if err != nil {
+		s.writeErrors(w, http.StatusInternalServerError, err)
+		return
+	}
+	body, err = json.Marshal(struct {
+		ID string `json:"id"`
+	}{ID: stub.ID})
+	if err != nil {
+		s.log().Error().Msgf("error marshaling stub id: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", defaultContentType)
 	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write(body); err != nil {
+		s.log().Error().Msgf("error writing stub id: %v", err)
+	}
+}
+
+// stubsHandler is the handler for the /httpmock/stubs endpoints.
+// A GET /httpmock/stubs/{id} request returns the stub with the given id as JSON.
+// A PUT /httpmock/stubs/{id} request with a JSON or YAML Stub body replaces the stub with the given id
+// and returns a 204 No Content, a 400 Bad Request if the stub is invalid
+// or a 500 Internal Server Error if the stub store fails to store it.
+// A DELETE /httpmock/stubs/{id} request removes the stub with the given id and returns a 204 No Content.
+// Requests for unknown stubs return a 404 Not Found.
+// A DELETE /httpmock/stubs request removes every stub and returns a 204 No Content.
+func (s *Server) stubsHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/httpmock/stubs"), "/")
+	switch {
+	case r.Method == http.MethodGet && id != "":
+		stub, ok := s.GetStub(id)
+		if !ok {
+			s.log().Error().Msgf("stub %s not found", id)
+			s.writeErrors(w, http.StatusNotFound, &ErrStubNotFound{ID: id})
+			return
+		}
+		body, err := json.MarshalIndent(stub, "", "  ")
+		if err != nil {
+			s.log().Error().Msgf("error marshaling stub: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write(body); err != nil {
+			s.log().Error().Msgf("error writing stub: %v", err)
+		}
+	case r.Method == http.MethodPut && id != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.log().Error().Msgf("error reading body: %v", err)
+			s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
+			return
+		}
+		stub, err := getStubFromBytes(body)
+		if err != nil {
+			s.log().Error().Msgf("failed to parse stub request: %v", err)
+			s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
+			return
+		}
+		if stub == nil {
+			s.log().Error().Msgf("empty stub request")
+			s.writeErrors(w, http.StatusBadRequest, &ErrEmptyStub{})
+			return
+		}
+		if errs := stub.bodyFileErrors(); len(errs) > 0 {
+			s.log().Error().Msgf("invalid stub: %v", errs)
+			s.writeErrors(w, http.StatusBadRequest, errs...)
+			return
+		}
+		if err := s.UpdateStub(id, stub); err != nil {
+			s.log().Error().Msgf("failed to update stub: %v", err)
+			var notFound *ErrStubNotFound
+			if errors.As(err, &notFound) {
+				s.writeErrors(w, http.StatusNotFound, err)
+				return
+			}
+			var invalid *ErrInvalidStub
+			if errors.As(err, &invalid) {
+				s.writeErrors(w, http.StatusBadRequest, err)
+				return
+			}
+			s.writeErrors(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && id != "":
+		if err := s.RemoveStub(id); err != nil {
+			s.log().Error().Msgf("failed to remove stub: %v", err)
+			var notFound *ErrStubNotFound
+			if errors.As(err, &notFound) {
+				s.writeErrors(w, http.StatusNotFound, err)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && id == "":
+		s.ClearStubs()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
+	}
 }
 
 // listStubsHandler is the handler for the /httpmock/list endpoint.
@@ -48,84 +166,214 @@ func (s *Server) addStubHandler(w http.ResponseWriter, r *http.Request) {
 // If no stubs are found, it returns an empty array.
 func (s *Server) listStubsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		log.Error().Msgf("method %s not allowed", r.Method)
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
 		return
 	}
 
-	values := make([]*Stub, 0, len(s.stubs))
-	for _, v := range s.stubs {
-		values = append(values, v)
-	}
-
-	body, err := json.MarshalIndent(values, "", "  ")
+	body, err := json.MarshalIndent(s.stubs.List(), "", "  ")
 	if err != nil {
-		log.Error().Msgf("error marshaling stubs: %v", err)
+		s.log().Error().Msgf("error marshaling stubs: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	if _, err := w.Write(body); err != nil {
-		log.Error().Msgf("error listing stubs: %v", err)
+		s.log().Error().Msgf("error listing stubs: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 }
 
-// genericStubHandler is the handler for any stub endpoint.
-// It returns the stub response if the current request is an existing stub request.
-// If the request is not a stub request, it returns a 404 Not Found.
-func (s *Server) genericStubHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Error().Msgf("error reading body: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+// callsHandler is the handler for the /httpmock/calls endpoint.
+// A GET request returns a JSON array with the number of times each stub has been served.
+// A DELETE request resets the call counters of every stub and returns a 204 No Content.
+func (s *Server) callsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		body, err := json.MarshalIndent(s.Calls(), "", "  ")
+		if err != nil {
+			s.log().Error().Msgf("error marshaling calls: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write(body); err != nil {
+			s.log().Error().Msgf("error listing calls: %v", err)
+		}
+	case http.MethodDelete:
+		s.ResetCalls()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
 	}
+}
 
-	// compact JSON body before hashing
-	var compactedBody string
-	if body != nil {
-		buff := new(bytes.Buffer)
-		if err := json.Compact(buff, body); err != nil {
-			log.Error().Msgf("error compacting body: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
+// scenariosHandler is the handler for the /httpmock/scenarios endpoints.
+// A GET /httpmock/scenarios request returns a JSON array with the scenarios and their current states.
+// A PUT /httpmock/scenarios/{name} request with a JSON or YAML Scenario body sets the state of the scenario
+// and returns a 204 No Content.
+// A DELETE /httpmock/scenarios request moves every scenario back to the Started state and returns a 204 No Content.
+func (s *Server) scenariosHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/httpmock/scenarios"), "/")
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		body, err := json.MarshalIndent(s.Scenarios(), "", "  ")
+		if err != nil {
+			s.log().Error().Msgf("error marshaling scenarios: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write(body); err != nil {
+			s.log().Error().Msgf("error listing scenarios: %v", err)
+		}
+	case r.Method == http.MethodPut && name != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.log().Error().Msgf("error reading body: %v", err)
+			s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
+			return
+		}
+		var scenario Scenario
+		if err := yaml.Unmarshal(body, &scenario); err != nil {
+			s.log().Error().Msgf("failed to parse scenario state: %v", err)
+			s.writeErrors(w, http.StatusBadRequest, &ErrInvalidBody{Err: err})
+			return
+		}
+		if scenario.State == "" {
+			s.log().Error().Msgf("scenario state is required")
+			s.writeErrors(w, http.StatusBadRequest, &FieldError{Field: "state", Err: &ErrRequiredState{}})
 			return
 		}
-		compactedBody = buff.String()
+		s.SetScenarioState(name, scenario.State)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && name == "":
+		s.ResetScenarios()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
 	}
+}
 
-	h := getHash(StubRequest{
-		Method: r.Method,
-		Path:   r.URL.Path,
-		Query:  r.URL.Query(),
-		Body:   compactedBody,
-	})
-	if stub, ok := s.stubs[h]; ok {
-		for k, v := range stub.Response.Headers {
-			for _, vv := range v {
-				w.Header().Add(k, vv)
-			}
+// requestsHandler is the handler for the /httpmock/requests endpoint.
+// A GET request returns a JSON array with the requests received by the server, oldest first,
+// filtered by the method, path and matched query parameters (e.g. ?method=POST&path=/v1/users/{id}&matched=false).
+// A DELETE request clears the requests received by the server and returns a 204 No Content.
+func (s *Server) requestsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := RequestFilter{
+			Method: query.Get("method"),
+			Path:   query.Get("path"),
 		}
-		if _, ok := w.Header()["Content-Type"]; !ok {
-			w.Header().Set("Content-Type", defaultContentType)
+		if v := query.Get("matched"); v != "" {
+			matched, err := strconv.ParseBool(v)
+			if err != nil {
+				s.log().Error().Msgf("invalid matched filter %s: %v", v, err)
+				s.writeErrors(w, http.StatusBadRequest, &FieldError{Field: "matched", Err: err})
+				return
+			}
+			filter.Matched = &matched
 		}
-		log.Info().Msgf("stub found: %s", stub.Request.String())
-		body, err := json.Marshal(stub.Response.Body)
+		body, err := json.MarshalIndent(s.FindRequests(filter), "", "  ")
 		if err != nil {
-			log.Error().Msgf("error marshaling stub body: %v", err)
+			s.log().Error().Msgf("error marshaling requests: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 		if _, err := w.Write(body); err != nil {
-			log.Error().Msgf("error writing stub body: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			s.log().Error().Msgf("error listing requests: %v", err)
+		}
+	case http.MethodDelete:
+		s.ClearRequests()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
+	}
+}
+
+// genericStubHandler is the handler for any stub endpoint.
+// It returns the stub response if the current request is an existing stub request.
+// If the request is not a stub request, it returns a 404 Not Found.
+func (s *Server) genericStubHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.log().Error().Msgf("error reading body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	stub, params, ok := s.findStub(r, string(body))
+	s.journal.add(LoggedRequest{
+		Method:    r.Method,
+		URL:       r.URL.String(),
+		Headers:   r.Header.Clone(),
+		Body:      string(body),
+		Timestamp: time.Now(),
+		Matched:   ok,
+		Stub:      stub,
+	})
+	if ok {
+		s.log().Info().Msgf("stub found: %s", stub.Request.String())
+		response, ok := s.nextResponse(stub)
+		if !ok {
+			s.log().Error().Msgf("stub responses exhausted: %s", stub.Request.String())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.transitionScenario(stub)
+		if !sleep(r.Context(), s.delay.duration()+response.Delay.duration()) {
+			s.log().Warn().Msgf("request canceled while delaying stub response: %s", stub.Request.String())
 			return
 		}
-		if stub.Response.StatusCode != http.StatusOK {
-			w.WriteHeader(stub.Response.StatusCode)
+		in := &incomingRequest{r: r, body: compactBody(body), params: params}
+		if err := response.write(w, in); err != nil {
+			s.log().Error().Msgf("error writing stub response: %v", err)
 		}
 		return
 	}
-	log.Error().Msgf("no stub found for request: %s %s %s", r.Method, r.URL.String(), compactedBody)
+	report := formatNearMiss(r, s.nearestStubs(r, string(body)))
+	s.log().Error().Msg(report)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusNotFound)
+	if _, err := io.WriteString(w, report+"\n"); err != nil {
+		s.log().Error().Msgf("error writing near misses: %v", err)
+	}
+}
+
+// nearMissesHandler is the handler for the /httpmock/near-misses endpoint.
+// A GET request returns a JSON array with the requests received by the server that didn't match any stub,
+// oldest first, along with the stubs nearest to matching them and the differences with each one.
+func (s *Server) nearMissesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.log().Error().Msgf("method %s not allowed", r.Method)
+		s.writeErrors(w, http.StatusMethodNotAllowed, &ErrMethodNotAllowed{Method: r.Method})
+		return
+	}
+	body, err := json.MarshalIndent(s.NearMisses(), "", "  ")
+	if err != nil {
+		s.log().Error().Msgf("error marshaling near misses: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		s.log().Error().Msgf("error listing near misses: %v", err)
+	}
+}
+
+// writeErrors writes the error document of the given errors with the given status code.
+func (s *Server) writeErrors(w http.ResponseWriter, statusCode int, errs ...error) {
+	body, err := json.MarshalIndent(newErrorDocument(errs), "", "  ")
+	if err != nil {
+		s.log().Error().Msgf("error marshaling errors: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", defaultContentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		s.log().Error().Msgf("error writing errors: %v", err)
+	}
 }
@@ -0,0 +1,288 @@
+package gmock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_genericStubHandler(t *testing.T) {
+	s := NewServer().WithStubs(
+		&Stub{
+			Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/{id}"},
+			Response: StubResponse{StatusCode: http.StatusOK, Body: map[string]any{"id": "${path.id}"}},
+		},
+		&Stub{
+			Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users", Body: map[string]any{"name": "slim"}},
+			Response: StubResponse{StatusCode: http.StatusCreated, Body: `{"id":1}`},
+		},
+		&Stub{
+			Request: StubRequest{Method: http.MethodGet, Path: "/index.html"},
+			Response: StubResponse{
+				StatusCode: http.StatusOK,
+				Headers:    http.Header{"Content-Type": {"text/html"}},
+				Body:       "<h1>gmock</h1>",
+			},
+		},
+	)
+
+	tests := []struct {
+		name            string
+		method          string
+		target          string
+		body            string
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "json body with path parameters",
+			method:          http.MethodGet,
+			target:          "/v1/users/42",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `{"id":"42"}`,
+		},
+		{
+			name:            "json string body and status code",
+			method:          http.MethodPost,
+			target:          "/v1/users",
+			body:            `{ "name": "slim" }`,
+			wantStatusCode:  http.StatusCreated,
+			wantContentType: "application/json",
+			wantBody:        `{"id":1}`,
+		},
+		{
+			name:            "raw body",
+			method:          http.MethodGet,
+			target:          "/index.html",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/html",
+			wantBody:        "<h1>gmock</h1>",
+		},
+		{
+			name:           "no stub found",
+			method:         http.MethodPost,
+			target:         "/v1/users",
+			body:           "name=slim",
+			wantStatusCode: http.StatusNotFound,
+			wantBody: `no stub found for request: POST /v1/users
+nearest stubs:
+  POST /v1/users {"name":"slim"}
+    body: expected {"name":"slim"} but was "name=slim"
+  GET /v1/users/{id}
+    method: expected GET but was "POST"
+    path: expected /v1/users/{id} but was "/v1/users"
+  GET /index.html
+    method: expected GET but was "POST"
+    path: expected /index.html but was "/v1/users"
+`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.genericStubHandler(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			}
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestServer_genericStubHandler_rawBody(t *testing.T) {
+	body := "{ \"a\": 1 }\n"
+	sum := sha256.Sum256([]byte(body))
+	s := NewServer().WithStubs(
+		&Stub{
+			Request:  StubRequest{Method: http.MethodPut, Path: "/blobs", BodyPatterns: []BodyPattern{{SHA256: hex.EncodeToString(sum[:])}}},
+			Response: StubResponse{StatusCode: http.StatusNoContent},
+		},
+		&Stub{
+			Request:  StubRequest{Method: http.MethodPost, Path: "/notes", BodyMatcher: &Matcher{EqualTo: "[1, 2]"}},
+			Response: StubResponse{StatusCode: http.StatusCreated},
+		},
+	)
+
+	r := httptest.NewRequest(http.MethodPut, "/blobs", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	s.genericStubHandler(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader("[1, 2]"))
+	r.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	s.genericStubHandler(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	rec := &recorder{TB: t}
+	assert.True(t, s.Verify(rec, Times(1), Request(http.MethodPost, "/notes").WithBody(Matcher{EqualTo: "[1, 2]"})))
+	assert.Empty(t, rec.errors)
+}
+
+func TestServer_addStubHandler(t *testing.T) {
+	s := NewServer()
+
+	w := httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(
+		`{"id": "get-user", "request": {"method": "GET", "path": "/v1/users/1"}, "response": {"status_code": 200}}`,
+	)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":"get-user"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/v1/users/2"}, "response": {"status_code": 200}}`,
+	)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	_, ok := s.GetStub(created.ID)
+	assert.True(t, ok)
+
+	w = httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(`{"request": {"path": "/v1/users"}}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"errors": [
+		{"field": "request.method", "code": "required_method", "message": "method is required"},
+		{"field": "response.status_code", "code": "invalid_status_code", "message": "status code 0 is not valid"}
+	]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/v1/users"}, "responses": [{"status_code": 200, "body_file": "../../etc/passwd"}]}`,
+	)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"errors": [{
+		"field": "responses[0].body_file",
+		"code": "unsafe_body_file",
+		"message": "body file ../../etc/passwd must be a relative path inside the files directory"
+	}]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(`[`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var doc ErrorDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Len(t, doc.Errors, 1)
+	assert.Equal(t, "invalid_body", doc.Errors[0].Code)
+
+	w = httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/add", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.JSONEq(t, `{"errors": [{"code": "method_not_allowed", "message": "method GET not allowed"}]}`, w.Body.String())
+}
+
+func TestServer_stubHandlers_storeError(t *testing.T) {
+	// the stubs directory of the store is a file, so that stub files can't be written
+	dir := filepath.Join(t.TempDir(), "stubs")
+	require.NoError(t, os.WriteFile(dir, nil, 0o600))
+	s := NewServer().WithStubStore(NewFileStubStore(dir, StubFormatJSON))
+
+	w := httptest.NewRecorder()
+	s.addStubHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/add", strings.NewReader(
+		`{"id": "get-user", "request": {"method": "GET", "path": "/v1/users/1"}, "response": {"status_code": 200}}`,
+	)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var doc ErrorDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Len(t, doc.Errors, 1)
+	assert.Equal(t, "store_error", doc.Errors[0].Code)
+
+	// the stub is added in memory only, so that it can be updated
+	stub := &Stub{ID: "get-user", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users/1"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	_, err := s.stubs.(*FileStubStore).memory.Add(stub)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/stubs/get-user", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/v2/users/1"}, "response": {"status_code": 200}}`,
+	)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"code": "store_error"`)
+}
+
+func TestServer_stubsHandler(t *testing.T) {
+	s := NewServer().WithStubs(
+		&Stub{
+			ID:       "get-user",
+			Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/{id}"},
+			Response: StubResponse{StatusCode: http.StatusOK},
+		},
+		&Stub{
+			ID:       "create-user",
+			Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users"},
+			Response: StubResponse{StatusCode: http.StatusCreated},
+		},
+	)
+
+	w := httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/stubs/get-user", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var stub Stub
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stub))
+	assert.Equal(t, "get-user", stub.ID)
+	assert.Equal(t, "/v1/users/{id}", stub.Request.Path)
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/stubs/get-user", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/v2/users/{id}"}, "response": {"status_code": 200}}`,
+	)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	updated, ok := s.GetStub("get-user")
+	require.True(t, ok)
+	assert.Equal(t, "/v2/users/{id}", updated.Request.Path)
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/stubs/get-user", strings.NewReader(`{"request": {"method": "GET"}}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"errors": [
+		{"field": "request.path", "code": "required_path", "message": "path is required"},
+		{"field": "response.status_code", "code": "invalid_status_code", "message": "status code 0 is not valid"}
+	]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/stubs/get-user", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/v1/users"}, "response": {"status_code": 200, "body_file": "/etc/passwd"}}`,
+	)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code": "unsafe_body_file"`)
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/stubs/get-user", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, ok = s.GetStub("get-user")
+	assert.False(t, ok)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		w = httptest.NewRecorder()
+		s.stubsHandler(w, httptest.NewRequest(method, "/httpmock/stubs/get-user", strings.NewReader(
+			`{"request": {"method": "GET", "path": "/v1/users"}, "response": {"status_code": 200}}`,
+		)))
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/stubs", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, s.stubs.List())
+
+	w = httptest.NewRecorder()
+	s.stubsHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/stubs", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,111 @@
+package gmock // nolint:golint
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultJournalSize = 1000 // the default number of requests kept in the journal
+
+// LoggedRequest is a request received by the server, along with the stub it matched (if any).
+type LoggedRequest struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Headers   http.Header `json:"headers"`
+	Body      string      `json:"body"`
+	Timestamp time.Time   `json:"timestamp"`
+	Matched   bool        `json:"matched"`
+	Stub      *Stub       `json:"stub,omitempty"`
+}
+
+// RequestFilter filters the requests of the journal.
+// Method and Path must be equal to the ones of the request, Path can also be a path template (e.g. /v1/users/{id}).
+// Matched, when set, filters matched or unmatched requests. Fields left empty are not taken into account.
+type RequestFilter struct {
+	Method  string
+	Path    string
+	Matched *bool
+}
+
+// match reports whether the logged request satisfies the filter.
+func (f *RequestFilter) match(r *LoggedRequest) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+		return false
+	}
+	if f.Path != "" {
+		path := strings.SplitN(r.URL, "?", 2)[0]
+		req := StubRequest{Path: f.Path}
+		req.Sanitize()
+		if _, _, ok := req.matchPath(path); !ok {
+			return false
+		}
+	}
+	if f.Matched != nil && *f.Matched != r.Matched {
+		return false
+	}
+	return true
+}
+
+// journal is a bounded in-memory log of the requests received by the server.
+// Once full, the oldest requests are discarded.
+type journal struct {
+	mu       sync.Mutex
+	size     int
+	requests []LoggedRequest
+}
+
+// newJournal creates a journal keeping up to size requests.
+func newJournal(size int) *journal {
+	if size <= 0 {
+		size = defaultJournalSize
+	}
+	return &journal{size: size}
+}
+
+// add logs a request.
+func (j *journal) add(r LoggedRequest) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.requests) >= j.size {
+		n := copy(j.requests, j.requests[len(j.requests)-j.size+1:])
+		j.requests = j.requests[:n]
+	}
+	j.requests = append(j.requests, r)
+}
+
+// find returns the logged requests satisfying the filter, oldest first.
+func (j *journal) find(f RequestFilter) []LoggedRequest {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	requests := make([]LoggedRequest, 0, len(j.requests))
+	for i := range j.requests {
+		if f.match(&j.requests[i]) {
+			requests = append(requests, j.requests[i])
+		}
+	}
+	return requests
+}
+
+// clear discards every logged request.
+func (j *journal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.requests = nil
+}
+
+// Requests returns the requests received by the server, oldest first.
+func (s *Server) Requests() []LoggedRequest {
+	return s.journal.find(RequestFilter{})
+}
+
+// FindRequests returns the requests received by the server satisfying the filter, oldest first.
+func (s *Server) FindRequests(f RequestFilter) []LoggedRequest {
+	return s.journal.find(f)
+}
+
+// ClearRequests clears the requests received by the server.
+func (s *Server) ClearRequests() {
+	s.journal.clear()
+}
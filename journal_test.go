@@ -0,0 +1,92 @@
+package gmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_journal_add(t *testing.T) {
+	j := newJournal(2)
+	j.add(LoggedRequest{URL: "/1"})
+	j.add(LoggedRequest{URL: "/2"})
+	j.add(LoggedRequest{URL: "/3"})
+	assert.Equal(t, []LoggedRequest{{URL: "/2"}, {URL: "/3"}}, j.find(RequestFilter{}))
+
+	j.clear()
+	assert.Empty(t, j.find(RequestFilter{}))
+}
+
+func TestRequestFilter_match(t *testing.T) {
+	matched, unmatched := true, false
+	r := &LoggedRequest{Method: http.MethodPost, URL: "/v1/users/42?expand=orders", Matched: true}
+
+	tests := []struct {
+		name   string
+		filter RequestFilter
+		want   bool
+	}{
+		{name: "no filter", filter: RequestFilter{}, want: true},
+		{name: "method", filter: RequestFilter{Method: "post"}, want: true},
+		{name: "other method", filter: RequestFilter{Method: http.MethodGet}, want: false},
+		{name: "path", filter: RequestFilter{Path: "/v1/users/42"}, want: true},
+		{name: "path template", filter: RequestFilter{Path: "/v1/users/{id}"}, want: true},
+		{name: "other path", filter: RequestFilter{Path: "/v1/users"}, want: false},
+		{name: "matched", filter: RequestFilter{Matched: &matched}, want: true},
+		{name: "unmatched", filter: RequestFilter{Matched: &unmatched}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.match(r))
+		})
+	}
+}
+
+func TestServer_requestsHandler(t *testing.T) {
+	stub := &Stub{
+		Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusCreated},
+	}
+	s := NewServer().WithStubs(stub)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": "slim"}`))
+	r.Header.Set("X-Correlation-Id", "abc-123")
+	s.genericStubHandler(httptest.NewRecorder(), r)
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users?page=2", nil))
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/v1/users", requests[0].URL)
+	assert.Equal(t, "abc-123", requests[0].Headers.Get("X-Correlation-Id"))
+	assert.Equal(t, `{"name": "slim"}`, requests[0].Body)
+	assert.True(t, requests[0].Matched)
+	assert.Equal(t, stub, requests[0].Stub)
+	assert.False(t, requests[0].Timestamp.IsZero())
+	assert.Equal(t, "/v1/users?page=2", requests[1].URL)
+	assert.False(t, requests[1].Matched)
+	assert.Nil(t, requests[1].Stub)
+
+	w := httptest.NewRecorder()
+	s.requestsHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/requests?matched=false", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var got []LoggedRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, http.MethodGet, got[0].Method)
+
+	w = httptest.NewRecorder()
+	s.requestsHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/requests?matched=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	s.requestsHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/requests", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, s.Requests())
+}
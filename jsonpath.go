@@ -0,0 +1,248 @@
+package gmock // nolint:golint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonPathOperators are the comparison operators supported by JSONPath expressions.
+// Two character operators come first so that they take precedence when parsing.
+var jsonPathOperators = []string{"==", "!=", ">=", "<=", "=~", ">", "<"}
+
+// jsonPathExpression is a JSONPath optionally followed by a comparison operator and a JSON value,
+// e.g. $.order.items[0].sku == "ABC" or $.amount > 100.
+// Without an operator, the expression only requires the path to exist.
+type jsonPathExpression struct {
+	path     []string
+	operator string
+	value    any
+}
+
+// parseJSONPathExpression parses a JSONPath expression.
+// Supported paths start with $ and are made of .name, ['name'], [n], [*] and .* selectors.
+func parseJSONPathExpression(s string) (*jsonPathExpression, error) {
+	path, operator, value := splitExpression(s, jsonPathOperators)
+	e := &jsonPathExpression{operator: operator}
+
+	var err error
+	if e.path, err = parseJSONPath(path); err != nil {
+		return nil, err
+	}
+	if operator == "" {
+		return e, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("missing value after operator %s", operator)
+	}
+	if e.value, err = decodeJSON(value); err != nil {
+		return nil, fmt.Errorf("invalid value %s: %v", value, err)
+	}
+	if operator == "=~" {
+		pattern, ok := e.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of operator =~ must be a string")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// splitExpression splits an expression into its left side, operator and right side.
+// Operators inside brackets or quotes are not taken into account.
+func splitExpression(s string, operators []string) (string, string, string) {
+	depth := 0
+	var quote rune
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0:
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					return strings.TrimSpace(s[:i]), op, strings.TrimSpace(s[i+len(op):])
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(s), "", ""
+}
+
+// parseJSONPath parses a JSONPath into the list of its selectors, where * selects every child.
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path %s must start with $", path)
+	}
+	var selectors []string
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("path %s has an empty selector", path)
+			}
+			selectors = append(selectors, name)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("path %s has an unclosed bracket", path)
+			}
+			name := strings.TrimSpace(rest[1:end])
+			if len(name) >= 2 && (name[0] == '\'' || name[0] == '"') && name[len(name)-1] == name[0] {
+				name = name[1 : len(name)-1]
+			} else if _, err := strconv.Atoi(name); err != nil && name != "*" {
+				return nil, fmt.Errorf("path %s has an invalid index %s", path, name)
+			}
+			selectors = append(selectors, name)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %s has an unexpected character %q", path, rest[0])
+		}
+	}
+	return selectors, nil
+}
+
+// selectJSONPath returns the values of the given decoded JSON document selected by the path selectors.
+func selectJSONPath(doc any, path []string) []any {
+	values := []any{doc}
+	for _, selector := range path {
+		var next []any
+		for _, v := range values {
+			switch t := v.(type) {
+			case map[string]any:
+				if selector == "*" {
+					for _, vv := range t {
+						next = append(next, vv)
+					}
+				} else if vv, ok := t[selector]; ok {
+					next = append(next, vv)
+				}
+			case []any:
+				if selector == "*" {
+					next = append(next, t...)
+				} else if i, err := strconv.Atoi(selector); err == nil {
+					if i < 0 {
+						i += len(t)
+					}
+					if i >= 0 && i < len(t) {
+						next = append(next, t[i])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// eval reports whether any of the values selected in the given decoded JSON document satisfies the expression.
+func (e *jsonPathExpression) eval(doc any) bool {
+	for _, v := range selectJSONPath(doc, e.path) {
+		if compareValues(v, e.operator, e.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues compares a decoded JSON value to an expected one with the given operator.
+// An empty operator is always satisfied.
+func compareValues(v any, operator string, expected any) bool {
+	switch operator {
+	case "":
+		return true
+	case "==":
+		return matchJSON(expected, v, false, false)
+	case "!=":
+		return !matchJSON(expected, v, false, false)
+	case "=~":
+		s, ok := scalarString(v)
+		if !ok {
+			return false
+		}
+		ok, err := regexp.MatchString(expected.(string), s)
+		return err == nil && ok
+	}
+
+	var cmp int
+	if a, b, ok := asFloats(v, expected); ok {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else if a, ok := v.(string); ok {
+		b, ok := expected.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(a, b)
+	} else {
+		return false
+	}
+
+	switch operator {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// asFloats returns both values as floats when both are JSON numbers.
+func asFloats(a, b any) (float64, float64, bool) {
+	an, ok := a.(json.Number)
+	if !ok {
+		return 0, 0, false
+	}
+	bn, ok := b.(json.Number)
+	if !ok {
+		return 0, 0, false
+	}
+	af, err := an.Float64()
+	if err != nil {
+		return 0, 0, false
+	}
+	bf, err := bn.Float64()
+	if err != nil {
+		return 0, 0, false
+	}
+	return af, bf, true
+}
+
+// scalarString returns the string representation of a decoded JSON scalar value.
+func scalarString(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
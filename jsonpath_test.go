@@ -0,0 +1,137 @@
+package gmock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseJSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "root",
+			path: "$",
+		},
+		{
+			name: "dot notation",
+			path: "$.order.items",
+			want: []string{"order", "items"},
+		},
+		{
+			name: "bracket notation",
+			path: "$['order'][\"items\"]",
+			want: []string{"order", "items"},
+		},
+		{
+			name: "indexes and wildcards",
+			path: "$.order.items[0].tags[*].*",
+			want: []string{"order", "items", "0", "tags", "*", "*"},
+		},
+		{
+			name:    "missing root",
+			path:    "order.items",
+			wantErr: true,
+		},
+		{
+			name:    "unclosed bracket",
+			path:    "$.items[0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid index",
+			path:    "$.items[first]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_jsonPathExpression_eval(t *testing.T) {
+	doc, err := decodeJSON(`{
+		"order": {
+			"id": "A-1",
+			"items": [
+				{"sku": "ABC", "quantity": 2},
+				{"sku": "DEF", "quantity": 5}
+			]
+		},
+		"amount": 150.5,
+		"paid": true,
+		"note": null
+	}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{expression: `$.order.items[0].sku == "ABC"`, want: true},
+		{expression: `$.order.items[1].sku == "ABC"`, want: false},
+		{expression: `$.order.items[-1].sku == "DEF"`, want: true},
+		{expression: `$.order.items[*].sku == "DEF"`, want: true},
+		{expression: `$.order.items[*].quantity > 4`, want: true},
+		{expression: `$.order.items[*].quantity > 5`, want: false},
+		{expression: `$.amount > 100`, want: true},
+		{expression: `$.amount >= 150.5`, want: true},
+		{expression: `$.amount < 100`, want: false},
+		{expression: `$.amount <= 150.5`, want: true},
+		{expression: `$.amount != 100`, want: true},
+		{expression: `$.paid == true`, want: true},
+		{expression: `$.note == null`, want: true},
+		{expression: `$.order.id =~ "^A-[0-9]+$"`, want: true},
+		{expression: `$.order.id > "A-0"`, want: true},
+		{expression: `$['order']['id'] == "A-1"`, want: true},
+		{expression: `$.order.items`, want: true},
+		{expression: `$.order.customer`, want: false},
+		{expression: `$.order.items[5].sku`, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := parseJSONPathExpression(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.eval(doc))
+		})
+	}
+}
+
+func Test_parseJSONPathExpression_invalid(t *testing.T) {
+	for _, expression := range []string{
+		`$.amount >`,
+		`$.amount > abc`,
+		`$.id =~ 1`,
+		`$.id =~ "[a-z"`,
+		`amount > 1`,
+	} {
+		_, err := parseJSONPathExpression(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestBodyPattern_match(t *testing.T) {
+	p := &BodyPattern{JSONPath: `$.order.items[0].sku == "ABC"`}
+	assert.Nil(t, p.Validate())
+	assert.True(t, p.match(`{"order":{"items":[{"sku":"ABC"}]}}`))
+	assert.False(t, p.match(`{"order":{"items":[{"sku":"DEF"}]}}`))
+	assert.False(t, p.match(`not json`))
+
+	p = &BodyPattern{JSONPath: `$.amount >`}
+	assert.Len(t, p.Validate(), 1)
+}
@@ -0,0 +1,93 @@
+package gmock // nolint:golint
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lineRegexp matches the line of the errors of the YAML decoder, e.g. yaml: line 3: did not find expected key.
+var lineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// fieldSegmentRegexp matches a segment of the field of a FieldError, e.g. responses[1].
+var fieldSegmentRegexp = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+
+// decodeStub decodes a JSON or YAML stub, along with its document node to locate its fields.
+// The stub is nil when the document is empty.
+func decodeStub(b []byte) (*Stub, *yaml.Node, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, nil, err
+	}
+	if node.Kind == 0 {
+		return nil, &node, nil
+	}
+	var stub Stub
+	if err := node.Decode(&stub); err != nil {
+		return nil, nil, err
+	}
+	return &stub, &node, nil
+}
+
+// stubFileErrors returns the errors of the stub file with the given name as StubErrors,
+// located at the position of the field they're about when the document node is known.
+func stubFileErrors(file string, node *yaml.Node, errs ...error) []error {
+	stubErrs := make([]error, 0, len(errs))
+	for _, err := range errs {
+		stubErr := &StubError{File: file, Err: err}
+		var fe *FieldError
+		if errors.As(err, &fe) && node != nil {
+			stubErr.Line, stubErr.Column = fieldPosition(node, fe.Field)
+		} else if m := lineRegexp.FindStringSubmatch(err.Error()); m != nil {
+			stubErr.Line, _ = strconv.Atoi(m[1])
+		}
+		stubErrs = append(stubErrs, stubErr)
+	}
+	return stubErrs
+}
+
+// fieldPosition returns the line and column of the given field (e.g. request.method or responses[1].status_code)
+// in the document node, or of its closest parent when it's missing.
+func fieldPosition(node *yaml.Node, field string) (int, int) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line, column := node.Line, node.Column
+	for _, segment := range strings.Split(field, ".") {
+		index := -1
+		if m := fieldSegmentRegexp.FindStringSubmatch(segment); m != nil {
+			segment = m[1]
+			index, _ = strconv.Atoi(m[2])
+		}
+		next := mappingValue(node, segment)
+		if next == nil {
+			return line, column
+		}
+		node = next
+		line, column = node.Line, node.Column
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return line, column
+			}
+			node = node.Content[index]
+			line, column = node.Line, node.Column
+		}
+	}
+	return line, column
+}
+
+// mappingValue returns the value of the given key of a mapping node, or nil if there's none.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
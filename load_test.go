@@ -0,0 +1,50 @@
+package gmock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fieldPosition(t *testing.T) {
+	_, node, err := decodeStub([]byte(`request:
+  method: FETCH
+  headers:
+    Accept:
+      matches: "("
+responses:
+  - status_code: 200
+  - status_code: 99
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		field      string
+		wantLine   int
+		wantColumn int
+	}{
+		{field: "request.method", wantLine: 2, wantColumn: 11},
+		{field: "request.headers.Accept", wantLine: 5, wantColumn: 7},
+		{field: "responses[1].status_code", wantLine: 8, wantColumn: 18},
+		{field: "request.path", wantLine: 2, wantColumn: 3},
+		{field: "responses[5]", wantLine: 7, wantColumn: 3},
+		{field: "scenario", wantLine: 1, wantColumn: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.field, func(t *testing.T) {
+			line, column := fieldPosition(node, tt.field)
+			assert.Equal(t, tt.wantLine, line)
+			assert.Equal(t, tt.wantColumn, column)
+		})
+	}
+}
+
+func Test_stubFileErrors(t *testing.T) {
+	_, err := getStubFromBytes([]byte("request:\n  method: [GET\n"))
+	require.Error(t, err)
+	errs := stubFileErrors("stubs/users.yaml", nil, err)
+	require.Len(t, errs, 1)
+	assert.Regexp(t, `^stubs/users.yaml:\d+: yaml: line \d+`, errs[0].Error())
+}
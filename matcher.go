@@ -0,0 +1,277 @@
+package gmock // nolint:golint
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matcher is a predicate used to match a single value of an incoming request (e.g. a header).
+// All the predicates set must be satisfied for the value to match.
+// A Matcher with no predicate set only requires the value to be present.
+type Matcher struct {
+	EqualTo         string `json:"equal_to,omitempty" yaml:"equal_to,omitempty"`
+	Contains        string `json:"contains,omitempty" yaml:"contains,omitempty"`
+	Matches         string `json:"matches,omitempty" yaml:"matches,omitempty"`
+	Absent          bool   `json:"absent,omitempty" yaml:"absent,omitempty"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty" yaml:"case_insensitive,omitempty"`
+
+	re *regexp.Regexp // the compiled Matches, see compile
+}
+
+// UnmarshalJSON allows a plain string to be used as a shorthand for an equal_to matcher.
+func (m *Matcher) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*m = Matcher{EqualTo: s}
+		return nil
+	}
+	type matcher Matcher
+	return json.Unmarshal(b, (*matcher)(m))
+}
+
+// UnmarshalYAML allows a plain string to be used as a shorthand for an equal_to matcher.
+func (m *Matcher) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = Matcher{EqualTo: value.Value}
+		return nil
+	}
+	type matcher Matcher
+	return value.Decode((*matcher)(m))
+}
+
+// Validate returns a list of validation errors for the current matcher.
+func (m *Matcher) Validate() []error {
+	var errs []error
+	if m.Matches != "" {
+		if _, err := regexp.Compile(m.pattern()); err != nil {
+			errs = append(errs, &ErrInvalidPattern{Pattern: m.Matches, Err: err})
+		}
+	}
+	return errs
+}
+
+// match reports whether any of the given values satisfies the matcher.
+// values is empty when the value is not present in the request.
+func (m *Matcher) match(values []string) bool {
+	if m.Absent {
+		return len(values) == 0
+	}
+	for _, v := range values {
+		if m.matchValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports whether a single present value satisfies the matcher.
+func (m *Matcher) matchValue(v string) bool {
+	if m.EqualTo != "" {
+		if m.CaseInsensitive && !strings.EqualFold(v, m.EqualTo) {
+			return false
+		}
+		if !m.CaseInsensitive && v != m.EqualTo {
+			return false
+		}
+	}
+	if m.Contains != "" {
+		if m.CaseInsensitive && !strings.Contains(strings.ToLower(v), strings.ToLower(m.Contains)) {
+			return false
+		}
+		if !m.CaseInsensitive && !strings.Contains(v, m.Contains) {
+			return false
+		}
+	}
+	if m.Matches != "" {
+		re, err := m.regexp()
+		if err != nil || !re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile compiles the regular expression of a valid matcher once, instead of on every match.
+func (m *Matcher) compile() {
+	m.re = nil
+	if m.Matches != "" {
+		m.re, _ = regexp.Compile(m.pattern())
+	}
+}
+
+// regexp returns the regular expression of the matcher, compiling it unless it's already compiled.
+func (m *Matcher) regexp() (*regexp.Regexp, error) {
+	if m.re != nil {
+		return m.re, nil
+	}
+	return regexp.Compile(m.pattern())
+}
+
+// compileMatchers compiles the regular expressions of the given valid matchers.
+func compileMatchers(matchers map[string]Matcher) {
+	for k, m := range matchers {
+		m.compile()
+		matchers[k] = m
+	}
+}
+
+// pattern returns the regular expression of the matcher, honoring case insensitivity.
+func (m *Matcher) pattern() string {
+	if m.CaseInsensitive {
+		return "(?i)" + m.Matches
+	}
+	return m.Matches
+}
+
+// match evaluates the stub request against an incoming request and its body.
+// It returns whether every predicate of the stub request is satisfied, a score
+// that grows with the number of satisfied predicates, used to pick the best match,
+// and the parameters captured from the request path.
+func (r *StubRequest) match(req *http.Request, body requestBody) (int, map[string]string, bool) {
+	if r.Method != req.Method {
+		return 0, nil, false
+	}
+	score := 1
+
+	params, exact, ok := r.matchPath(req.URL.Path)
+	if !ok {
+		return 0, nil, false
+	}
+	// exact paths are more specific than templates, wildcards and patterns
+	score++
+	if exact {
+		score++
+	}
+
+	query := req.URL.Query()
+	for k, v := range r.Query {
+		if !reflect.DeepEqual(v, query[k]) {
+			return 0, nil, false
+		}
+		score++
+	}
+	for k, m := range r.QueryParams {
+		if !m.match(query[k]) {
+			return 0, nil, false
+		}
+		score++
+	}
+
+	for k, m := range r.Headers {
+		if !m.match(req.Header.Values(k)) {
+			return 0, nil, false
+		}
+		score++
+	}
+
+	if r.Body != nil {
+		if !r.matchBody(body) {
+			return 0, nil, false
+		}
+		score++
+	}
+	if r.BodyMatcher != nil {
+		var values []string
+		if body.raw != "" {
+			values = []string{body.raw}
+		}
+		if !r.BodyMatcher.match(values) {
+			return 0, nil, false
+		}
+		score++
+	}
+	if len(r.FormParams) > 0 {
+		form, ok := formValues(req.Header.Get("Content-Type"), body.raw)
+		if !ok {
+			return 0, nil, false
+		}
+		for k, m := range r.FormParams {
+			if !m.match(form[k]) {
+				return 0, nil, false
+			}
+			score++
+		}
+	}
+	for _, p := range r.BodyPatterns {
+		if !p.match(body.raw) {
+			return 0, nil, false
+		}
+		score++
+	}
+
+	return score, params, true
+}
+
+// compile compiles the path, matchers and body patterns of a valid stub request once,
+// instead of on every match. It must be called again when the stub request changes.
+func (r *StubRequest) compile() {
+	r.pathRegexp = nil
+	if re, err := r.pathPatternRegexp(); err == nil {
+		r.pathRegexp = re
+	}
+	compileMatchers(r.QueryParams)
+	compileMatchers(r.Headers)
+	compileMatchers(r.FormParams)
+	if r.BodyMatcher != nil {
+		r.BodyMatcher.compile()
+	}
+	for i := range r.BodyPatterns {
+		r.BodyPatterns[i].compile()
+	}
+}
+
+// pathPatternRegexp returns the regular expression of the path pattern or path template of the stub request,
+// compiling it unless it's already compiled, or nil when the path is neither.
+func (r *StubRequest) pathPatternRegexp() (*regexp.Regexp, error) {
+	switch {
+	case r.pathRegexp != nil:
+		return r.pathRegexp, nil
+	case r.PathPattern != "":
+		return pathPatternRegexp(r.PathPattern)
+	case isPathTemplate(r.Path):
+		return pathTemplateRegexp(r.Path)
+	default:
+		return nil, nil
+	}
+}
+
+// matchPath matches the stub request path (or path pattern) against the given path.
+// It returns the captured path parameters and whether the path matched exactly.
+func (r *StubRequest) matchPath(path string) (map[string]string, bool, bool) {
+	re, err := r.pathPatternRegexp()
+	if err != nil {
+		return nil, false, false
+	}
+	if re == nil {
+		return nil, true, r.Path == path
+	}
+	params, ok := matchPath(re, path)
+	return params, false, ok
+}
+
+// matchBody matches the stub request body against the given body, compacted when it's JSON.
+// Bodies that aren't JSON are only equal to string stub request bodies with the same content.
+func (r *StubRequest) matchBody(body requestBody) bool {
+	if !body.json {
+		s, ok := r.Body.(string)
+		return ok && s == body.raw
+	}
+	compacted, err := compactJSON(r.Body)
+	if err != nil {
+		return false
+	}
+	expected, err := decodeJSON(compacted)
+	if err != nil {
+		return false
+	}
+	actual, err := decodeJSON(body.compacted)
+	if err != nil {
+		return false
+	}
+	return matchJSON(expected, actual, r.PartialBody, r.IgnoreArrayOrder)
+}
@@ -0,0 +1,168 @@
+package gmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatcher_match(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		values  []string
+		want    bool
+	}{
+		{
+			name:    "equal to",
+			matcher: Matcher{EqualTo: "Bearer token"},
+			values:  []string{"Bearer token"},
+			want:    true,
+		},
+		{
+			name:    "not equal to",
+			matcher: Matcher{EqualTo: "Bearer token"},
+			values:  []string{"Bearer other"},
+			want:    false,
+		},
+		{
+			name:    "equal to any of the values",
+			matcher: Matcher{EqualTo: "text/html"},
+			values:  []string{"application/json", "text/html"},
+			want:    true,
+		},
+		{
+			name:    "matches",
+			matcher: Matcher{Matches: "^tenant-[0-9]+$"},
+			values:  []string{"tenant-42"},
+			want:    true,
+		},
+		{
+			name:    "does not match",
+			matcher: Matcher{Matches: "^tenant-[0-9]+$"},
+			values:  []string{"tenant-x"},
+			want:    false,
+		},
+		{
+			name:    "equal to case insensitive",
+			matcher: Matcher{EqualTo: "application/json", CaseInsensitive: true},
+			values:  []string{"Application/JSON"},
+			want:    true,
+		},
+		{
+			name:    "contains",
+			matcher: Matcher{Contains: "json"},
+			values:  []string{"application/json; charset=utf-8"},
+			want:    true,
+		},
+		{
+			name:    "does not contain",
+			matcher: Matcher{Contains: "JSON"},
+			values:  []string{"application/json"},
+			want:    false,
+		},
+		{
+			name:    "contains case insensitive",
+			matcher: Matcher{Contains: "JSON", CaseInsensitive: true},
+			values:  []string{"application/json"},
+			want:    true,
+		},
+		{
+			name:    "matches case insensitive",
+			matcher: Matcher{Matches: "^tenant-[a-z]+$", CaseInsensitive: true},
+			values:  []string{"Tenant-ACME"},
+			want:    true,
+		},
+		{
+			name:    "all predicates must be satisfied",
+			matcher: Matcher{Contains: "json", Matches: "^text/"},
+			values:  []string{"application/json"},
+			want:    false,
+		},
+		{
+			name:    "absent",
+			matcher: Matcher{Absent: true},
+			want:    true,
+		},
+		{
+			name:    "not absent",
+			matcher: Matcher{Absent: true},
+			values:  []string{"value"},
+			want:    false,
+		},
+		{
+			name:    "present",
+			matcher: Matcher{},
+			values:  []string{"value"},
+			want:    true,
+		},
+		{
+			name:    "not present",
+			matcher: Matcher{EqualTo: "value"},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.matcher.match(tt.values))
+		})
+	}
+}
+
+func TestStubRequest_compile(t *testing.T) {
+	r := &StubRequest{
+		Method:       http.MethodPost,
+		Path:         "/v1/users/{id}",
+		Headers:      map[string]Matcher{"Accept": {Matches: "json$"}},
+		QueryParams:  map[string]Matcher{"page": {Matches: "^[0-9]+$"}},
+		BodyPatterns: []BodyPattern{{JSONPath: "$.name == \"slim\""}},
+	}
+	r.compile()
+	assert.NotNil(t, r.pathRegexp)
+	assert.NotNil(t, r.Headers["Accept"].re)
+	assert.NotNil(t, r.QueryParams["page"].re)
+	assert.NotNil(t, r.BodyPatterns[0].jsonPath)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/1?page=2", nil)
+	req.Header.Set("Accept", "application/json")
+	_, params, ok := r.match(req, newRequestBody("", []byte(`{"name":"slim"}`)))
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "1"}, params)
+	_, _, ok = r.match(req, newRequestBody("", []byte(`{"name":"shady"}`)))
+	assert.False(t, ok)
+
+	// exact paths aren't compiled
+	r = &StubRequest{Method: http.MethodGet, Path: "/v1/users"}
+	r.compile()
+	assert.Nil(t, r.pathRegexp)
+}
+
+func TestMatcher_Validate(t *testing.T) {
+	m := &Matcher{Matches: "^[a-z]+$"}
+	assert.Nil(t, m.Validate())
+
+	m = &Matcher{Matches: "[a-z"}
+	assert.Len(t, m.Validate(), 1)
+}
+
+func TestMatcher_Unmarshal(t *testing.T) {
+	var got map[string]Matcher
+	require.NoError(t, json.Unmarshal([]byte(`{"Accept":"application/json","X-Tenant":{"matches":"^t-"}}`), &got))
+	assert.Equal(t, map[string]Matcher{
+		"Accept":   {EqualTo: "application/json"},
+		"X-Tenant": {Matches: "^t-"},
+	}, got)
+
+	got = nil
+	require.NoError(t, yaml.Unmarshal([]byte("Accept: application/json\nAuthorization:\n  absent: true\n"), &got))
+	assert.Equal(t, map[string]Matcher{
+		"Accept":        {EqualTo: "application/json"},
+		"Authorization": {Absent: true},
+	}, got)
+}
@@ -0,0 +1,80 @@
+package gmock // nolint:golint
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const nearestStubs = 3 // the number of nearest stubs reported for an unmatched request
+
+// Candidate is a stub that didn't match a request, along with how far it's from matching it.
+// Distance goes from 0 (every field matches) to 1 (none does).
+type Candidate struct {
+	Stub     *Stub       `json:"stub"`
+	Distance float64     `json:"distance"`
+	Diff     []FieldDiff `json:"diff"`
+}
+
+// NearMiss is a request that didn't match any stub, along with its nearest stubs.
+type NearMiss struct {
+	Request    LoggedRequest `json:"request"`
+	Candidates []Candidate   `json:"candidates"`
+}
+
+// nearestStubs returns the stubs nearest to matching the given request and its body, nearest first.
+func (s *Server) nearestStubs(r *http.Request, body string) []Candidate {
+	b := newRequestBody(r.Header.Get("Content-Type"), []byte(body))
+	stubs := s.stubs.List()
+	candidates := make([]Candidate, 0, len(stubs))
+	for _, stub := range stubs {
+		diff := stub.Request.diff(r, b)
+		if stub.Scenario != "" && stub.RequiredState != "" {
+			state := s.scenarioState(stub.Scenario)
+			diff = append(diff, FieldDiff{
+				Field:    "scenario " + stub.Scenario,
+				Expected: stub.RequiredState,
+				Actual:   state,
+				Match:    state == stub.RequiredState,
+			})
+		}
+		candidates = append(candidates, Candidate{Stub: stub, Distance: distance(diff), Diff: diff})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+	if len(candidates) > nearestStubs {
+		candidates = candidates[:nearestStubs]
+	}
+	return candidates
+}
+
+// NearMisses returns the requests received by the server that didn't match any stub, oldest first,
+// along with the stubs currently nearest to matching them.
+func (s *Server) NearMisses() []NearMiss {
+	matched := false
+	requests := s.journal.find(RequestFilter{Matched: &matched})
+	misses := make([]NearMiss, 0, len(requests))
+	for _, logged := range requests {
+		req, body, err := logged.httpRequest()
+		if err != nil {
+			continue
+		}
+		misses = append(misses, NearMiss{Request: logged, Candidates: s.nearestStubs(req, body)})
+	}
+	return misses
+}
+
+// formatNearMiss returns a human-readable report of a request that didn't match any stub and its nearest stubs.
+func formatNearMiss(r *http.Request, candidates []Candidate) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "no stub found for request: %s %s", r.Method, r.URL.String())
+	if len(candidates) > 0 {
+		b.WriteString("\nnearest stubs:")
+		for _, c := range candidates {
+			fmt.Fprintf(&b, "\n  %s\n%s", c.Stub.Request.String(), strings.TrimRight(formatDiff(c.Diff, "    "), "\n"))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package gmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_nearestStubs(t *testing.T) {
+	users := &Stub{Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	orders := &Stub{Request: StubRequest{Method: http.MethodGet, Path: "/v1/orders"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	health := &Stub{Request: StubRequest{Method: http.MethodGet, Path: "/health"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	metrics := &Stub{Request: StubRequest{Method: http.MethodGet, Path: "/metrics"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	s := NewServer().WithStubs(health, orders, users, metrics)
+
+	candidates := s.nearestStubs(httptest.NewRequest(http.MethodGet, "/v1/user", nil), "")
+	require.Len(t, candidates, nearestStubs)
+	assert.Same(t, users, candidates[0].Stub)
+	assert.Same(t, orders, candidates[1].Stub)
+	assert.Equal(t, []FieldDiff{
+		{Field: "method", Expected: "GET", Actual: "GET", Match: true},
+		{Field: "path", Expected: "/v1/users", Actual: "/v1/user", Match: false},
+	}, candidates[0].Diff)
+	assert.Greater(t, candidates[0].Distance, 0.0)
+	assert.Less(t, candidates[0].Distance, candidates[1].Distance)
+}
+
+func TestServer_nearestStubs_scenario(t *testing.T) {
+	stub := &Stub{
+		Request:       StubRequest{Method: http.MethodGet, Path: "/orders/1"},
+		Scenario:      "order",
+		RequiredState: "Created",
+		Response:      StubResponse{StatusCode: http.StatusOK},
+	}
+	s := NewServer().WithStubs(stub)
+
+	candidates := s.nearestStubs(httptest.NewRequest(http.MethodGet, "/orders/1", nil), "")
+	require.Len(t, candidates, 1)
+	assert.Contains(t, candidates[0].Diff, FieldDiff{Field: "scenario order", Expected: "Created", Actual: ScenarioStarted})
+}
+
+func TestServer_nearMissesHandler(t *testing.T) {
+	stub := &Stub{
+		Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users", Body: map[string]any{"name": "slim"}},
+		Response: StubResponse{StatusCode: http.StatusCreated},
+	}
+	s := NewServer().WithStubs(stub)
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": "slim"}`)))
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"name": "shady"}`)))
+
+	w := httptest.NewRecorder()
+	s.nearMissesHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/near-misses", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var misses []NearMiss
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &misses))
+	require.Len(t, misses, 1)
+	assert.Equal(t, `{"name": "shady"}`, misses[0].Request.Body)
+	require.Len(t, misses[0].Candidates, 1)
+	assert.Equal(t, []FieldDiff{
+		{Field: "method", Expected: "POST", Actual: "POST", Match: true},
+		{Field: "path", Expected: "/v1/users", Actual: "/v1/users", Match: true},
+		{Field: "body", Expected: `{"name":"slim"}`, Actual: `{"name":"shady"}`, Match: false},
+	}, misses[0].Candidates[0].Diff)
+
+	w = httptest.NewRecorder()
+	s.nearMissesHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/near-misses", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,93 @@
+package gmock // nolint:golint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pathParamRegexp matches the {name} parameters of a path template.
+var pathParamRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// pathParamPlaceholderRegexp matches the ${path.name} placeholders of a stub response.
+var pathParamPlaceholderRegexp = regexp.MustCompile(`\$\{path\.(\w+)\}`)
+
+// isPathTemplate reports whether the given path has parameters or wildcards.
+func isPathTemplate(path string) bool {
+	return strings.Contains(path, "*") || pathParamRegexp.MatchString(path)
+}
+
+// pathTemplateRegexp converts a path template into a regular expression.
+// {name} matches a single segment captured as the name parameter,
+// * matches a single segment and ** matches any number of segments.
+func pathTemplateRegexp(path string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if segment == "**" {
+			b.WriteString("(?:/.*)?")
+			continue
+		}
+		b.WriteString("/")
+		if segment == "*" {
+			b.WriteString("[^/]+")
+			continue
+		}
+		last := 0
+		for _, loc := range pathParamRegexp.FindAllStringSubmatchIndex(segment, -1) {
+			b.WriteString(segmentRegexp(segment[last:loc[0]]))
+			b.WriteString("(?P<" + segment[loc[2]:loc[3]] + ">[^/]+)")
+			last = loc[1]
+		}
+		b.WriteString(segmentRegexp(segment[last:]))
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// pathPatternRegexp compiles a path pattern, anchored to match the whole path.
+func pathPatternRegexp(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// segmentRegexp converts a literal path segment into a regular expression, where * matches any characters but /.
+func segmentRegexp(segment string) string {
+	parts := strings.Split(segment, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return strings.Join(parts, "[^/]*")
+}
+
+// matchPath matches the given path against a compiled path regular expression
+// and returns the named groups captured by it.
+func matchPath(re *regexp.Regexp, path string) (map[string]string, bool) {
+	m := re.FindStringSubmatch(path)
+	if m == nil {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if i > 0 && name != "" {
+			params[name] = m[i]
+		}
+	}
+	return params, true
+}
+
+// expandPathParams replaces the ${path.name} placeholders found in the strings of the given value
+// with the path parameters captured when matching the stub request.
+func expandPathParams(v any, params map[string]string) any {
+	if len(params) == 0 {
+		return v
+	}
+	expanded, _ := mapStrings(v, func(s string) (string, error) {
+		return pathParamPlaceholderRegexp.ReplaceAllStringFunc(s, func(placeholder string) string {
+			name := pathParamPlaceholderRegexp.FindStringSubmatch(placeholder)[1]
+			if p, ok := params[name]; ok {
+				return p
+			}
+			return placeholder
+		}), nil
+	})
+	return expanded
+}
@@ -0,0 +1,135 @@
+package gmock
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isPathTemplate(t *testing.T) {
+	assert.False(t, isPathTemplate("/v1/users"))
+	assert.True(t, isPathTemplate("/v1/users/{id}"))
+	assert.True(t, isPathTemplate("/files/*"))
+	assert.True(t, isPathTemplate("/static/**"))
+}
+
+func Test_pathTemplateRegexp(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		path       string
+		wantParams map[string]string
+		wantOk     bool
+	}{
+		{
+			name:       "parameter",
+			template:   "/v1/users/{id}",
+			path:       "/v1/users/42",
+			wantParams: map[string]string{"id": "42"},
+			wantOk:     true,
+		},
+		{
+			name:       "multiple parameters",
+			template:   "/v1/users/{userId}/orders/{orderId}",
+			path:       "/v1/users/42/orders/7",
+			wantParams: map[string]string{"userId": "42", "orderId": "7"},
+			wantOk:     true,
+		},
+		{
+			name:       "parameter inside segment",
+			template:   "/reports/{name}.csv",
+			path:       "/reports/sales.csv",
+			wantParams: map[string]string{"name": "sales"},
+			wantOk:     true,
+		},
+		{
+			name:     "parameter does not match multiple segments",
+			template: "/v1/users/{id}",
+			path:     "/v1/users/42/orders",
+		},
+		{
+			name:       "single segment wildcard",
+			template:   "/files/*",
+			path:       "/files/report.pdf",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:     "single segment wildcard does not match multiple segments",
+			template: "/files/*",
+			path:     "/files/2022/report.pdf",
+		},
+		{
+			name:       "wildcard inside segment",
+			template:   "/files/*.pdf",
+			path:       "/files/report.pdf",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:       "multi segment wildcard",
+			template:   "/static/**",
+			path:       "/static/css/main.css",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:       "multi segment wildcard matches no segment",
+			template:   "/static/**",
+			path:       "/static",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:       "multi segment wildcard in the middle",
+			template:   "/api/**/health",
+			path:       "/api/v1/internal/health",
+			wantParams: map[string]string{},
+			wantOk:     true,
+		},
+		{
+			name:     "literal characters are escaped",
+			template: "/v1.0/{id}",
+			path:     "/v100/42",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := pathTemplateRegexp(tt.template)
+			require.NoError(t, err)
+			params, ok := matchPath(re, tt.path)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.wantParams, params)
+			}
+		})
+	}
+}
+
+func Test_matchPath(t *testing.T) {
+	re := regexp.MustCompile(`^/v1/users/(?P<id>[0-9]+)$`)
+	params, ok := matchPath(re, "/v1/users/42")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "42"}, params)
+
+	_, ok = matchPath(re, "/v1/users/slim")
+	assert.False(t, ok)
+}
+
+func Test_expandPathParams(t *testing.T) {
+	params := map[string]string{"id": "42"}
+	assert.Equal(t, "/v1/users/42", expandPathParams("/v1/users/${path.id}", params))
+	assert.Equal(t, "${path.other}", expandPathParams("${path.other}", params))
+	assert.Equal(t, map[string]any{
+		"id":    "42",
+		"links": []any{"/v1/users/42"},
+		"age":   30,
+	}, expandPathParams(map[string]any{
+		"id":    "${path.id}",
+		"links": []any{"/v1/users/${path.id}"},
+		"age":   30,
+	}, params))
+}
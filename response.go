@@ -0,0 +1,229 @@
+package gmock // nolint:golint
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BodyMode is the way the body of a stub response is written.
+type BodyMode string
+
+const (
+	// BodyModeJSON writes the body as JSON. String bodies holding a JSON document are written as they are.
+	BodyModeJSON BodyMode = "json"
+	// BodyModeRaw writes a string body as it is (e.g. HTML, XML, CSV or plain text).
+	BodyModeRaw BodyMode = "raw"
+	// BodyModeBase64 decodes a base64 string body and writes the resulting bytes (e.g. images or PDFs).
+	BodyModeBase64 BodyMode = "base64"
+)
+
+// contentType returns the content type used for stub responses in the body mode that don't set one.
+func (m BodyMode) contentType() string {
+	switch m {
+	case BodyModeRaw:
+		return "text/plain; charset=utf-8"
+	case BodyModeBase64:
+		return "application/octet-stream"
+	default:
+		return defaultContentType
+	}
+}
+
+// binaryMediaTypes are the media types, besides image/*, audio/*, video/* and font/*,
+// whose string bodies are decoded from base64 when no body mode is set.
+var binaryMediaTypes = map[string]struct{}{
+	"application/octet-stream": {},
+	"application/pdf":          {},
+	"application/zip":          {},
+	"application/gzip":         {},
+	"application/x-gzip":       {},
+	"application/x-tar":        {},
+	"application/protobuf":     {},
+	"application/x-protobuf":   {},
+	"application/msgpack":      {},
+	"application/x-msgpack":    {},
+	"application/cbor":         {},
+	"application/wasm":         {},
+}
+
+// isBinaryMediaType returns whether the given media type is a known binary one.
+func isBinaryMediaType(mediaType string) bool {
+	if _, ok := binaryMediaTypes[mediaType]; ok {
+		return true
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyMode returns the body mode of the stub response.
+// When not set, it's inferred from the Content-Type header:
+// JSON content types (and no content type at all) are written as JSON,
+// known binary content types (e.g. images or PDFs) are decoded from base64
+// and any other content type (e.g. text, XML or forms) is written raw.
+// Bodies that aren't strings are always written as JSON unless a mode is set.
+func (r *StubResponse) bodyMode() BodyMode {
+	if r.BodyMode != "" {
+		return r.BodyMode
+	}
+	if _, ok := r.Body.(string); !ok {
+		return BodyModeJSON
+	}
+	contentType := http.Header(r.Headers).Get("Content-Type")
+	if contentType == "" {
+		return BodyModeJSON
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return BodyModeRaw
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "json"):
+		return BodyModeJSON
+	case isBinaryMediaType(mediaType):
+		return BodyModeBase64
+	default:
+		return BodyModeRaw
+	}
+}
+
+// bodyBytes returns the bytes of the stub response body according to its body mode,
+// expanded with the data of the given incoming request.
+func (r *StubResponse) bodyBytes(in *incomingRequest) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	switch mode := r.bodyMode(); mode {
+	case BodyModeRaw:
+		s, ok := r.Body.(string)
+		if !ok {
+			return nil, fmt.Errorf("body must be a string in %s body mode", mode)
+		}
+		expanded, err := r.expand(s, in)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(expanded.(string)), nil
+	case BodyModeBase64:
+		s, ok := r.Body.(string)
+		if !ok {
+			return nil, fmt.Errorf("body must be a string in %s body mode", mode)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		body, err := r.expand(r.Body, in)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := body.(string); ok && json.Valid([]byte(s)) {
+			return []byte(s), nil
+		}
+		return json.Marshal(body)
+	}
+}
+
+// expand replaces the ${path.name} placeholders found in the strings of the given value
+// with the path parameters of the incoming request and, when enabled, executes them as templates.
+func (r *StubResponse) expand(v any, in *incomingRequest) (any, error) {
+	v = expandPathParams(v, in.params)
+	if !r.Template {
+		return v, nil
+	}
+	return mapStrings(v, func(s string) (string, error) {
+		return renderTemplate(s, in)
+	})
+}
+
+// write writes the stub response for the given incoming request. Body files are streamed from disk.
+func (r *StubResponse) write(w http.ResponseWriter, in *incomingRequest) error {
+	for k, v := range r.Headers {
+		for _, vv := range v {
+			expanded, err := r.expand(vv, in)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return fmt.Errorf("error expanding stub header %s: %w", k, err)
+			}
+			w.Header().Add(k, expanded.(string))
+		}
+	}
+
+	if r.Fault != "" {
+		return r.writeFault(w, in)
+	}
+
+	if r.BodyFile != "" {
+		return r.writeBodyFile(w)
+	}
+
+	if _, ok := w.Header()["Content-Type"]; !ok {
+		w.Header().Set("Content-Type", r.bodyMode().contentType())
+	}
+	body, err := r.bodyBytes(in)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("error encoding stub body: %w", err)
+	}
+	w.WriteHeader(r.StatusCode)
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error writing stub body: %w", err)
+	}
+	return nil
+}
+
+// writeBodyFile streams the body file of the stub response.
+// The Content-Type is inferred from the file extension when the stub response doesn't set one.
+func (r *StubResponse) writeBodyFile(w http.ResponseWriter) error {
+	f, err := os.Open(r.bodyFilePath)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("error opening stub body file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("error reading stub body file: %w", err)
+	}
+
+	if _, ok := w.Header()["Content-Type"]; !ok {
+		contentType := mime.TypeByExtension(filepath.Ext(r.BodyFile))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(r.StatusCode)
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("error writing stub body file: %w", err)
+	}
+	return nil
+}
+
+// resolveBodyFile resolves a relative body file of the stub response against the given directory
+// and checks that it exists. BodyFile is kept as it is.
+func (r *StubResponse) resolveBodyFile(dir string) error {
+	if r.BodyFile == "" {
+		return nil
+	}
+	path := r.BodyFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return &ErrInvalidBodyFile{BodyFile: path}
+	}
+	r.bodyFilePath = path
+	return nil
+}
@@ -0,0 +1,159 @@
+package gmock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubResponse_bodyMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		response StubResponse
+		want     BodyMode
+	}{
+		{
+			name:     "explicit",
+			response: StubResponse{Body: "a,b", BodyMode: BodyModeRaw, Headers: http.Header{"Content-Type": {"application/json"}}},
+			want:     BodyModeRaw,
+		},
+		{
+			name:     "no content type",
+			response: StubResponse{Body: `{"to":"json"}`},
+			want:     BodyModeJSON,
+		},
+		{
+			name:     "json content type",
+			response: StubResponse{Body: `{"to":"json"}`, Headers: http.Header{"Content-Type": {"application/problem+json"}}},
+			want:     BodyModeJSON,
+		},
+		{
+			name:     "text content type",
+			response: StubResponse{Body: "<html></html>", Headers: http.Header{"Content-Type": {"text/html; charset=utf-8"}}},
+			want:     BodyModeRaw,
+		},
+		{
+			name:     "xml content type",
+			response: StubResponse{Body: "<order/>", Headers: http.Header{"Content-Type": {"application/xml"}}},
+			want:     BodyModeRaw,
+		},
+		{
+			name:     "binary content type",
+			response: StubResponse{Body: "JVBERi0=", Headers: http.Header{"Content-Type": {"application/pdf"}}},
+			want:     BodyModeBase64,
+		},
+		{
+			name:     "image content type",
+			response: StubResponse{Body: "iVBORw0KGgo=", Headers: http.Header{"Content-Type": {"image/png"}}},
+			want:     BodyModeBase64,
+		},
+		{
+			name:     "form content type",
+			response: StubResponse{Body: "a=b&c=d", Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}},
+			want:     BodyModeRaw,
+		},
+		{
+			name:     "yaml content type",
+			response: StubResponse{Body: "a: b", Headers: http.Header{"Content-Type": {"application/yaml"}}},
+			want:     BodyModeRaw,
+		},
+		{
+			name:     "binary content type without a string body",
+			response: StubResponse{Body: map[string]any{"to": "json"}, Headers: http.Header{"Content-Type": {"application/pdf"}}},
+			want:     BodyModeJSON,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.response.bodyMode())
+		})
+	}
+}
+
+func TestStubResponse_bodyBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		response StubResponse
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "no body",
+			response: StubResponse{},
+			want:     "",
+		},
+		{
+			name:     "json string",
+			response: StubResponse{Body: `{"to":"json"}`},
+			want:     `{"to":"json"}`,
+		},
+		{
+			name:     "json object",
+			response: StubResponse{Body: map[string]any{"id": "${path.id}"}},
+			want:     `{"id":"42"}`,
+		},
+		{
+			name:     "json text",
+			response: StubResponse{Body: "text", BodyMode: BodyModeJSON},
+			want:     `"text"`,
+		},
+		{
+			name:     "raw",
+			response: StubResponse{Body: "id,name\n${path.id},slim\n", BodyMode: BodyModeRaw},
+			want:     "id,name\n42,slim\n",
+		},
+		{
+			name:     "raw without a string body",
+			response: StubResponse{Body: 42, BodyMode: BodyModeRaw},
+			wantErr:  true,
+		},
+		{
+			name:     "base64",
+			response: StubResponse{Body: "iVBORw0KGgo=", BodyMode: BodyModeBase64},
+			want:     "\x89PNG\r\n\x1a\n",
+		},
+		{
+			name:     "invalid base64",
+			response: StubResponse{Body: "not base64!", BodyMode: BodyModeBase64},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.response.bodyBytes(&incomingRequest{params: map[string]string{"id": "42"}})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestStubResponse_write_bodyFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "report.csv"), []byte("id,name\n1,slim\n"), 0o600))
+
+	r := &StubResponse{StatusCode: http.StatusOK, BodyFile: "report.csv"}
+	require.NoError(t, r.resolveBodyFile(dir))
+	assert.Equal(t, "report.csv", r.BodyFile)
+	assert.Equal(t, filepath.Join(dir, "report.csv"), r.bodyFilePath)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, r.write(w, &incomingRequest{}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "15", w.Header().Get("Content-Length"))
+	assert.Equal(t, "id,name\n1,slim\n", w.Body.String())
+
+	r = &StubResponse{StatusCode: http.StatusOK, BodyFile: "missing.csv"}
+	assert.Error(t, r.resolveBodyFile(dir))
+}
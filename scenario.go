@@ -0,0 +1,79 @@
+package gmock // nolint:golint
+
+import "sort"
+
+// ScenarioStarted is the state every scenario starts in.
+const ScenarioStarted = "Started"
+
+// Scenario is a named state machine shared by stubs.
+// A stub of a scenario with a required state only matches when the scenario is in that state,
+// and moves the scenario to its new state (if any) when served.
+type Scenario struct {
+	Name  string `json:"name" yaml:"name"`
+	State string `json:"state" yaml:"state"`
+}
+
+// matchScenario reports whether the scenario of the stub (if any) is in the state the stub requires,
+// state returns the current state of a scenario.
+func (r *Stub) matchScenario(state func(string) string) bool {
+	if r.Scenario == "" || r.RequiredState == "" {
+		return true
+	}
+	return state(r.Scenario) == r.RequiredState
+}
+
+// scenarioState returns the current state of the given scenario.
+func (s *Server) scenarioState(name string) string {
+	s.scenariosMu.Lock()
+	defer s.scenariosMu.Unlock()
+	if state, ok := s.scenarios[name]; ok {
+		return state
+	}
+	return ScenarioStarted
+}
+
+// transitionScenario moves the scenario of the served stub to its new state.
+func (s *Server) transitionScenario(stub *Stub) {
+	if stub.Scenario == "" || stub.NewState == "" {
+		return
+	}
+	s.SetScenarioState(stub.Scenario, stub.NewState)
+}
+
+// Scenarios returns the scenarios of the stubs of the server, sorted by name, along with their current states.
+func (s *Server) Scenarios() []Scenario {
+	names := make(map[string]struct{})
+	for _, stub := range s.stubs.List() {
+		if stub.Scenario != "" {
+			names[stub.Scenario] = struct{}{}
+		}
+	}
+	s.scenariosMu.Lock()
+	for name := range s.scenarios {
+		names[name] = struct{}{}
+	}
+	s.scenariosMu.Unlock()
+
+	scenarios := make([]Scenario, 0, len(names))
+	for name := range names {
+		scenarios = append(scenarios, Scenario{Name: name, State: s.scenarioState(name)})
+	}
+	sort.Slice(scenarios, func(i, j int) bool {
+		return scenarios[i].Name < scenarios[j].Name
+	})
+	return scenarios
+}
+
+// SetScenarioState sets the current state of the given scenario.
+func (s *Server) SetScenarioState(name, state string) {
+	s.scenariosMu.Lock()
+	defer s.scenariosMu.Unlock()
+	s.scenarios[name] = state
+}
+
+// ResetScenarios moves every scenario back to the Started state.
+func (s *Server) ResetScenarios() {
+	s.scenariosMu.Lock()
+	defer s.scenariosMu.Unlock()
+	s.scenarios = make(map[string]string)
+}
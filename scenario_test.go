@@ -0,0 +1,101 @@
+package gmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_scenario(t *testing.T) {
+	s := NewServer().WithStubs(
+		&Stub{
+			Request:       StubRequest{Method: http.MethodGet, Path: "/orders/1"},
+			Response:      StubResponse{StatusCode: http.StatusNoContent},
+			Scenario:      "order",
+			RequiredState: ScenarioStarted,
+		},
+		&Stub{
+			Request:       StubRequest{Method: http.MethodPost, Path: "/orders"},
+			Response:      StubResponse{StatusCode: http.StatusCreated},
+			Scenario:      "order",
+			RequiredState: ScenarioStarted,
+			NewState:      "created",
+		},
+		&Stub{
+			Request:       StubRequest{Method: http.MethodGet, Path: "/orders/1"},
+			Response:      StubResponse{StatusCode: http.StatusOK, Body: map[string]any{"id": 1}},
+			Scenario:      "order",
+			RequiredState: "created",
+		},
+	)
+
+	call := func(method, target string) int {
+		w := httptest.NewRecorder()
+		s.genericStubHandler(w, httptest.NewRequest(method, target, nil))
+		return w.Code
+	}
+	assert.Len(t, s.stubs.List(), 3)
+	assert.Equal(t, http.StatusNoContent, call(http.MethodGet, "/orders/1"))
+	assert.Equal(t, []Scenario{{Name: "order", State: ScenarioStarted}}, s.Scenarios())
+
+	assert.Equal(t, http.StatusCreated, call(http.MethodPost, "/orders"))
+	assert.Equal(t, []Scenario{{Name: "order", State: "created"}}, s.Scenarios())
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/orders/1"))
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/orders"))
+
+	s.ResetScenarios()
+	assert.Equal(t, http.StatusNoContent, call(http.MethodGet, "/orders/1"))
+
+	s.SetScenarioState("order", "created")
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/orders/1"))
+}
+
+func TestServer_scenariosHandler(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request:       StubRequest{Method: http.MethodGet, Path: "/orders/1"},
+		Response:      StubResponse{StatusCode: http.StatusOK},
+		Scenario:      "order",
+		RequiredState: "created",
+	})
+
+	w := httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/scenarios/order", strings.NewReader(`{"state":"created"}`)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/scenarios", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var scenarios []Scenario
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &scenarios))
+	assert.Equal(t, []Scenario{{Name: "order", State: "created"}}, scenarios)
+
+	w = httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest(http.MethodPut, "/httpmock/scenarios/order", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/scenarios", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, []Scenario{{Name: "order", State: ScenarioStarted}}, s.Scenarios())
+
+	w = httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/scenarios", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestStub_validationErrors_scenario(t *testing.T) {
+	stub := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/test"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+		NewState: "done",
+	}
+	assert.Len(t, stub.validationErrors(), 1)
+
+	stub.Scenario = "test"
+	assert.Nil(t, stub.validationErrors())
+}
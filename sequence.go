@@ -0,0 +1,66 @@
+package gmock // nolint:golint
+
+// ExhaustedPolicy is what a stub with a sequence of responses does once every response has been served.
+type ExhaustedPolicy string
+
+const (
+	// ExhaustedRepeatLast keeps serving the last response of the sequence.
+	ExhaustedRepeatLast ExhaustedPolicy = "repeat_last"
+	// ExhaustedCycle starts over from the first response of the sequence.
+	ExhaustedCycle ExhaustedPolicy = "cycle"
+	// ExhaustedNotFound returns a 404 Not Found.
+	ExhaustedNotFound ExhaustedPolicy = "not_found"
+)
+
+// StubCalls is the number of times a stub has been served.
+type StubCalls struct {
+	Stub  *Stub `json:"stub"`
+	Calls int   `json:"calls"`
+}
+
+// response returns the response of the stub for the given (zero based) call,
+// or false when the stub has nothing left to serve.
+func (r *Stub) response(call int) (*StubResponse, bool) {
+	if len(r.Responses) == 0 {
+		return &r.Response, true
+	}
+	if call < len(r.Responses) {
+		return &r.Responses[call], true
+	}
+	switch r.AfterExhausted {
+	case ExhaustedCycle:
+		return &r.Responses[call%len(r.Responses)], true
+	case ExhaustedNotFound:
+		return nil, false
+	default:
+		return &r.Responses[len(r.Responses)-1], true
+	}
+}
+
+// nextResponse returns the response of the stub for its next call and increments its call counter.
+func (s *Server) nextResponse(stub *Stub) (*StubResponse, bool) {
+	s.callsMu.Lock()
+	call := s.calls[stub]
+	s.calls[stub]++
+	s.callsMu.Unlock()
+	return stub.response(call)
+}
+
+// Calls returns the number of times each stub has been served.
+func (s *Server) Calls() []StubCalls {
+	s.callsMu.Lock()
+	defer s.callsMu.Unlock()
+	stubs := s.stubs.List()
+	calls := make([]StubCalls, 0, len(stubs))
+	for _, stub := range stubs {
+		calls = append(calls, StubCalls{Stub: stub, Calls: s.calls[stub]})
+	}
+	return calls
+}
+
+// ResetCalls resets the call counters of every stub, restarting their sequences of responses.
+func (s *Server) ResetCalls() {
+	s.callsMu.Lock()
+	defer s.callsMu.Unlock()
+	s.calls = make(map[*Stub]int)
+}
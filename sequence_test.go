@@ -0,0 +1,111 @@
+package gmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStub_response(t *testing.T) {
+	responses := []StubResponse{
+		{StatusCode: http.StatusServiceUnavailable},
+		{StatusCode: http.StatusServiceUnavailable},
+		{StatusCode: http.StatusOK},
+	}
+	tests := []struct {
+		name string
+		stub Stub
+		want []int
+	}{
+		{
+			name: "single response",
+			stub: Stub{Response: StubResponse{StatusCode: http.StatusOK}},
+			want: []int{200, 200, 200, 200},
+		},
+		{
+			name: "repeat last",
+			stub: Stub{Responses: responses},
+			want: []int{503, 503, 200, 200, 200},
+		},
+		{
+			name: "cycle",
+			stub: Stub{Responses: responses, AfterExhausted: ExhaustedCycle},
+			want: []int{503, 503, 200, 503, 503},
+		},
+		{
+			name: "not found",
+			stub: Stub{Responses: responses, AfterExhausted: ExhaustedNotFound},
+			want: []int{503, 503, 200, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for call, want := range tt.want {
+				r, ok := tt.stub.response(call)
+				assert.Equal(t, want != 0, ok)
+				if ok {
+					assert.Equal(t, want, r.StatusCode)
+				}
+			}
+		})
+	}
+}
+
+func TestStub_validationErrors_responses(t *testing.T) {
+	stub := &Stub{
+		Request:   StubRequest{Method: http.MethodGet, Path: "/test"},
+		Responses: []StubResponse{{StatusCode: http.StatusAccepted}, {StatusCode: http.StatusOK}},
+	}
+	assert.Nil(t, stub.validationErrors())
+
+	stub.AfterExhausted = "unknown"
+	assert.Len(t, stub.validationErrors(), 1)
+
+	stub.AfterExhausted = ExhaustedCycle
+	stub.Responses = append(stub.Responses, StubResponse{StatusCode: -1})
+	assert.Len(t, stub.validationErrors(), 1)
+}
+
+func TestServer_callsHandler(t *testing.T) {
+	stub := &Stub{
+		Request: StubRequest{Method: http.MethodGet, Path: "/jobs/1"},
+		Responses: []StubResponse{
+			{StatusCode: http.StatusOK, Body: map[string]any{"status": "pending"}},
+			{StatusCode: http.StatusOK, Body: map[string]any{"status": "done"}},
+		},
+		AfterExhausted: ExhaustedNotFound,
+	}
+	s := NewServer().WithStubs(stub)
+
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.genericStubHandler(w, httptest.NewRequest(http.MethodGet, "/jobs/1", nil))
+		return w
+	}
+	assert.JSONEq(t, `{"status":"pending"}`, call().Body.String())
+	assert.JSONEq(t, `{"status":"done"}`, call().Body.String())
+	assert.Equal(t, http.StatusNotFound, call().Code)
+
+	w := httptest.NewRecorder()
+	s.callsHandler(w, httptest.NewRequest(http.MethodGet, "/httpmock/calls", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var calls []StubCalls
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &calls))
+	require.Len(t, calls, 1)
+	assert.Equal(t, 3, calls[0].Calls)
+
+	w = httptest.NewRecorder()
+	s.callsHandler(w, httptest.NewRequest(http.MethodDelete, "/httpmock/calls", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, s.Calls()[0].Calls)
+	assert.JSONEq(t, `{"status":"pending"}`, call().Body.String())
+
+	w = httptest.NewRecorder()
+	s.callsHandler(w, httptest.NewRequest(http.MethodPost, "/httpmock/calls", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
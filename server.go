@@ -1,16 +1,21 @@
 package gmock // nolint:golint
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,178 +23,498 @@ const (
 	defaultStubsDir    = "stubs"            // the default location for stubs
 	defaultContentType = "application/json" // the default content type for stubs
 	defaultPort        = 8080               // the default port for the server
+	bodyFilesDir       = "files"            // the directories of body files among stubs, not loaded as stubs
 )
 
 // Config is used to configure the server.
+// Port is the port the server listens on, 8080 by default.
+// RandomPort makes the server listen on a port chosen by the system instead (see Server.URL), e.g. for parallel tests.
+// Listener is the listener the server serves on instead of listening on Port, e.g. a Unix domain socket.
+// FilesDir is the directory relative body files are resolved against, for stubs that aren't loaded from files.
+// Delay delays every stub response, on top of the delay of the stub response itself.
+// JournalSize is the number of received requests kept by the server (1000 by default).
+// StubStore stores the stubs of the server, in memory when nil.
+// Logger logs the activity of the server, the global zerolog logger is used when nil.
+// Strict makes NewServerWithConfig panic when stubs can't be loaded, instead of skipping them.
 type Config struct {
-	Port     int
-	StubsDir string
-	Stubs    []*Stub
+	Port        int
+	RandomPort  bool
+	Listener    net.Listener
+	StubsDir    string
+	FilesDir    string
+	Delay       *Delay
+	JournalSize int
+	StubStore   StubStore
+	Stubs       []*Stub
+	Logger      *zerolog.Logger
+	Strict      bool
 }
 
 // Server is the HTTP mock server.
 type Server struct {
-	stubs stubHashMap
-	dir   string
-	port  int
-	srv   *http.Server
+	stubs    StubStore
+	dir      string
+	filesDir string
+	delay    *Delay
+	port     int
+	listener net.Listener
+	addr     net.Addr
+	srv      *http.Server
+	calls    map[*Stub]int
+	callsMu  sync.Mutex
+
+	scenarios   map[string]string
+	scenariosMu sync.Mutex
+
+	journal *journal
+	logger  *zerolog.Logger
+
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
 // NewServer creates a new server.
 func NewServer() *Server {
 	s := &Server{
-		stubs: make(stubHashMap),
-		dir:   defaultStubsDir,
-		port:  defaultPort,
+		stubs:     NewMemoryStubStore(),
+		dir:       defaultStubsDir,
+		port:      defaultPort,
+		calls:     make(map[*Stub]int),
+		scenarios: make(map[string]string),
+		journal:   newJournal(defaultJournalSize),
+		ready:     make(chan struct{}),
 	}
 	return s
 }
 
 // NewServerWithConfig creates a new server with the given config.
+// Stubs that can't be loaded are logged and skipped, unless the config is strict, in which case it panics.
 func NewServerWithConfig(config Config) *Server {
+	s, err := NewServerWithConfigE(config)
+	if err != nil {
+		if config.Strict {
+			panic(err)
+		}
+		s.log().Error().Msgf("failed to load stubs: %v", err)
+	}
+	return s
+}
+
+// NewServerWithConfigE creates a new server with the given config,
+// returning an ErrStubs with every stub that can't be loaded, which are skipped.
+func NewServerWithConfigE(config Config) (*Server, error) {
 	s := NewServer()
 	if config.Port > 0 {
 		s.port = config.Port
 	}
-	s.addStubs(config.Stubs...)
-	s.loadStubs(config.StubsDir)
-	return s
+	if config.RandomPort {
+		s.port = 0
+	}
+	s.listener = config.Listener
+	s.filesDir = config.FilesDir
+	s.delay = config.Delay
+	s.logger = config.Logger
+	s.journal = newJournal(config.JournalSize)
+	if config.StubStore != nil {
+		s.stubs = config.StubStore
+	}
+	errs := s.addStubs(config.Stubs...)
+	s.dir = config.StubsDir
+	if config.StubsDir != "" {
+		errs = append(errs, s.loadStubs(config.StubsDir)...)
+	}
+	return s, stubsError(errs)
 }
 
-// Start starts the server.
-func (s *Server) Start() {
+// Start starts the server, loading the stubs of the default stubs directory unless stubs were loaded from another one.
+// Stubs that can't be loaded are logged and skipped.
+// The port is bound before Start returns, so the server is ready to accept requests unless an error is returned.
+func (s *Server) Start() error {
 	if s.dir == defaultStubsDir {
-		s.loadStubs(defaultStubsDir)
+		for _, err := range s.loadDefaultStubs() {
+			s.log().Error().Msgf("failed to load stub: %v", err)
+		}
+	}
+	if err := s.listen(); err != nil {
+		s.log().Error().Msgf("failed to start HTTP mock server: %v", err)
+		return fmt.Errorf("failed to start HTTP mock server: %w", err)
 	}
+	return nil
+}
+
+// StartE starts the server like Start, but returns an ErrStubs with every stub of the default stubs directory
+// that can't be loaded (in which case the server isn't started) or the error listening on the port.
+func (s *Server) StartE() error {
+	if s.dir == defaultStubsDir {
+		if err := stubsError(s.loadDefaultStubs()); err != nil {
+			return err
+		}
+	}
+	if err := s.listen(); err != nil {
+		return fmt.Errorf("failed to start HTTP mock server: %w", err)
+	}
+	return nil
+}
+
+// handler returns the handler of the admin and stub endpoints.
+func (s *Server) handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/httpmock/add", s.addStubHandler)
 	mux.HandleFunc("/httpmock/list", s.listStubsHandler)
+	mux.HandleFunc("/httpmock/stubs", s.stubsHandler)
+	mux.HandleFunc("/httpmock/stubs/", s.stubsHandler)
+	mux.HandleFunc("/httpmock/calls", s.callsHandler)
+	mux.HandleFunc("/httpmock/scenarios", s.scenariosHandler)
+	mux.HandleFunc("/httpmock/scenarios/", s.scenariosHandler)
+	mux.HandleFunc("/httpmock/requests", s.requestsHandler)
+	mux.HandleFunc("/httpmock/near-misses", s.nearMissesHandler)
 	mux.HandleFunc("/", s.genericStubHandler)
+	return mux
+}
+
+// listen binds the port of the server, unless it has a listener, and serves the admin and stub endpoints
+// on it in the background. The server is ready as soon as it returns without error.
+func (s *Server) listen() error {
+	l := s.listener
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", fmt.Sprintf(":%d", s.port)); err != nil {
+			return err
+		}
+	}
+	s.addr = l.Addr()
 	s.srv = &http.Server{
-		Addr:              fmt.Sprintf(":%d", s.port),
-		Handler:           mux,
+		Handler:           s.handler(),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	s.log().Info().Msgf("HTTP mock server listening on %s %s", l.Addr().Network(), l.Addr())
+	s.readyOnce.Do(func() { close(s.ready) })
 	go func() {
-		log.Info().Msgf("starting HTTP mock server on port %d", s.port)
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error().Msgf("failed to start HTTP mock server: %v", err)
+		if err := s.srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			s.log().Error().Msgf("HTTP mock server stopped serving: %v", err)
 		}
 	}()
-	time.Sleep(time.Second)
+	return nil
+}
+
+// Addr returns the address the server listens on, or nil until it's started.
+// With port 0, it holds the port chosen by the system.
+func (s *Server) Addr() net.Addr {
+	select {
+	case <-s.ready:
+		return s.addr
+	default:
+		return nil
+	}
+}
+
+// URL returns the base URL of the server (e.g. http://127.0.0.1:8080), or an empty string until it's started
+// or when it doesn't listen on TCP (e.g. on a Unix domain socket).
+func (s *Server) URL() string {
+	addr, ok := s.Addr().(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	host := "127.0.0.1" // reachable when listening on every interface
+	if addr.IP != nil && !addr.IP.IsUnspecified() {
+		host = addr.IP.String()
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
+
+// Ready returns a channel closed once the server is listening and accepting requests.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// WaitReady waits until the server is listening and accepting requests, or the context is done.
+func (s *Server) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Stop stops the server.
 func (s *Server) Stop() error {
+	if s.srv == nil {
+		return nil
+	}
 	if err := s.srv.Shutdown(context.Background()); err != nil {
-		log.Error().Msgf("failed to stop HTTP mock server: %v", err)
+		s.log().Error().Msgf("failed to stop HTTP mock server: %v", err)
 		return err
 	}
-	log.Info().Msgf("HTTP mock server stopped")
+	s.log().Info().Msgf("HTTP mock server stopped")
 	return nil
 }
 
-// AddStub adds a stub to the server.
+// AddStub adds a stub to the server, invalid stubs are logged and skipped.
 func (s *Server) AddStub(stub *Stub) {
 	s.addStub(stub)
 }
 
-// AddStubs adds multiple stubs to the server.
+// AddStubE adds a stub to the server, returning an ErrInvalidStub with its validation errors if it's invalid,
+// or an ErrStoreStub if the stub store fails to add it.
+func (s *Server) AddStubE(stub *Stub) error {
+	errs, err := s.addStub(stub)
+	if len(errs) > 0 {
+		return &ErrInvalidStub{Errs: errs}
+	}
+	return err
+}
+
+// GetStub returns the stub with the given id.
+func (s *Server) GetStub(id string) (*Stub, bool) {
+	return s.stubs.Get(id)
+}
+
+// UpdateStub replaces the stub with the given id, keeping its id, and resets its call counter.
+func (s *Server) UpdateStub(id string, stub *Stub) error {
+	existing, ok := s.stubs.Get(id)
+	if !ok {
+		return &ErrStubNotFound{ID: id}
+	}
+	stub.ID = id
+	stub.generatedID = existing.generatedID
+	errs, err := s.addStub(stub)
+	if len(errs) > 0 {
+		return &ErrInvalidStub{Errs: errs}
+	}
+	return err
+}
+
+// RemoveStub removes the stub with the given id, along with its call counter.
+func (s *Server) RemoveStub(id string) error {
+	stub, ok := s.stubs.Get(id)
+	if !ok {
+		return &ErrStubNotFound{ID: id}
+	}
+	if err := s.stubs.Remove(id); err != nil {
+		return err
+	}
+	s.callsMu.Lock()
+	delete(s.calls, stub)
+	s.callsMu.Unlock()
+	s.log().Info().Msgf("removed stub: %s", stub.Request.String())
+	return nil
+}
+
+// AddStubs adds multiple stubs to the server, invalid stubs are logged and skipped.
 func (s *Server) AddStubs(stubs ...*Stub) {
 	s.addStubs(stubs...)
 }
 
+// AddStubsE adds multiple stubs to the server, returning an ErrStubs with the validation errors
+// of every invalid stub (e.g. stubs[1].request.method), which are skipped.
+func (s *Server) AddStubsE(stubs ...*Stub) error {
+	return stubsError(s.addStubs(stubs...))
+}
+
 // WithStubs adds multiple stubs to the server.
 func (s *Server) WithStubs(stubs ...*Stub) *Server {
 	s.addStubs(stubs...)
 	return s
 }
 
-// WithStubsFrom loads stubs from the given location.
+// WithStubsFrom loads stubs from the given location, stubs that can't be loaded are logged and skipped.
 func (s *Server) WithStubsFrom(stubsLocation string) *Server {
-	s.loadStubs(stubsLocation)
+	for _, err := range s.loadStubs(stubsLocation) {
+		s.log().Error().Msgf("failed to load stub: %v", err)
+	}
 	return s
 }
 
+// LoadStubs loads stubs from the given location, returning an ErrStubs with every stub that can't be loaded
+// along with its file and the line and column of the error in it, if known. Those stubs are skipped.
+func (s *Server) LoadStubs(location string) error {
+	return stubsError(s.loadStubs(location))
+}
+
 // ClearStubs clears all stubs from the server.
 func (s *Server) ClearStubs() {
-	s.stubs = make(stubHashMap)
+	if err := s.stubs.Clear(); err != nil {
+		s.log().Error().Msgf("failed to clear stubs: %v", err)
+	}
+	s.ResetCalls()
+	s.ResetScenarios()
+}
+
+// WithStubStore sets the store of the stubs of the server.
+func (s *Server) WithStubStore(store StubStore) *Server {
+	s.stubs = store
+	return s
 }
 
-// WithPort sets the port for the server.
+// WithPort sets the port for the server, 0 for a port chosen by the system when the server starts.
 func (s *Server) WithPort(port int) *Server {
 	s.port = port
 	return s
 }
 
-// loadStubs loads stubs from the given location.
-func (s *Server) loadStubs(location string) {
+// WithListener sets the listener the server serves on instead of listening on its port,
+// e.g. a Unix domain socket. The listener is closed when the server stops.
+func (s *Server) WithListener(l net.Listener) *Server {
+	s.listener = l
+	return s
+}
+
+// WithLogger sets the logger of the activity of the server, instead of the global zerolog logger.
+func (s *Server) WithLogger(logger zerolog.Logger) *Server {
+	s.logger = &logger
+	return s
+}
+
+// log returns the logger of the server.
+func (s *Server) log() *zerolog.Logger {
+	if s.logger == nil {
+		return &log.Logger
+	}
+	return s.logger
+}
+
+// WithFilesDir sets the directory relative body files are resolved against,
+// for stubs that aren't loaded from files.
+func (s *Server) WithFilesDir(dir string) *Server {
+	s.filesDir = dir
+	return s
+}
+
+// WithDelay sets the delay of every stub response, on top of the delay of the stub response itself.
+func (s *Server) WithDelay(delay *Delay) *Server {
+	s.delay = delay
+	return s
+}
+
+// loadStubs loads stubs from the given location, recursively, skipping the directories of body files.
+// Stubs that can't be read, parsed or added don't prevent the other ones from being loaded, their errors are returned.
+func (s *Server) loadStubs(location string) []error {
 	s.dir = location
 	files, err := os.ReadDir(location)
 	if err != nil {
-		log.Warn().Msgf("failed to read stubs directory: %s %v", location, err)
-		return
+		return []error{&StubError{File: location, Err: err}}
 	}
+	var errs []error
 	for _, file := range files {
+		path := filepath.Join(location, file.Name())
 		if file.IsDir() {
-			s.loadStubs(filepath.Join(location, file.Name()))
+			if file.Name() != bodyFilesDir {
+				errs = append(errs, s.loadStubs(path)...)
+			}
 			continue
 		}
 		// only load .json and .yaml/.yml files
 		if !strings.HasSuffix(file.Name(), ".json") && !strings.HasSuffix(file.Name(), ".yaml") && !strings.HasSuffix(file.Name(), ".yml") {
 			continue
 		}
-		fileBytes, err := os.ReadFile(fmt.Sprintf("%s/%s", location, file.Name()))
+		fileBytes, err := os.ReadFile(path)
 		if err != nil {
-			log.Error().Msgf("failed to read stub file: %v", err)
-			return
+			errs = append(errs, &StubError{File: path, Err: err})
+			continue
 		}
 
-		stub, err := getStubFromBytes(fileBytes)
+		stub, node, err := decodeStub(fileBytes)
 		if err != nil {
-			log.Error().Msgf("failed to parse stub file %s: %v", file.Name(), err)
-			return
+			errs = append(errs, stubFileErrors(path, nil, err)...)
+			continue
 		}
+		if stub == nil {
+			errs = append(errs, &StubError{File: path, Err: &ErrEmptyStub{}})
+			continue
+		}
+		stub.source = path
+		stub.generatedID = generatedID(node)
+		stubErrs, err := s.addStub(stub)
+		if len(stubErrs) > 0 {
+			errs = append(errs, stubFileErrors(path, node, stubErrs...)...)
+		}
+		if err != nil {
+			errs = append(errs, &StubError{File: path, Err: err})
+		}
+	}
+	return errs
+}
 
-		s.addStub(stub)
+// loadDefaultStubs loads stubs from the default stubs directory, if it exists.
+func (s *Server) loadDefaultStubs() []error {
+	if _, err := os.Stat(defaultStubsDir); errors.Is(err, fs.ErrNotExist) {
+		return nil
 	}
+	return s.loadStubs(defaultStubsDir)
 }
 
-// addStub adds a stub to the server.
-func (s *Server) addStub(stub *Stub) []error {
+// addStub adds a stub to the server, returning its validation errors if it's invalid,
+// or an ErrStoreStub if the stub store fails to add it.
+func (s *Server) addStub(stub *Stub) ([]error, error) {
 	stub.Request.Sanitize()
 	if errs := stub.validationErrors(); len(errs) > 0 {
-		log.Error().Msgf("invalid stub: %v", errs)
-		return errs
+		s.log().Error().Msgf("invalid stub: %v", errs)
+		return errs, nil
+	}
+	// body files are relative to the stub file, if any
+	dir := s.filesDir
+	if stub.source != "" {
+		dir = filepath.Dir(stub.source)
+	}
+	if err := stub.Response.resolveBodyFile(dir); err != nil {
+		s.log().Error().Msgf("invalid stub: %v", err)
+		return []error{&FieldError{Field: "response.body_file", Err: err}}, nil
+	}
+	for i := range stub.Responses {
+		if err := stub.Responses[i].resolveBodyFile(dir); err != nil {
+			s.log().Error().Msgf("invalid stub: %v", err)
+			return []error{&FieldError{Field: fmt.Sprintf("responses[%d].body_file", i), Err: err}}, nil
+		}
 	}
 
-	// compact JSON body before hashing
-	if stub.Request.Body != nil {
-		bodyBytes, err := json.Marshal(stub.Request.Body)
+	// compact JSON body before matching, text bodies are kept as they are
+	stub.receivedBody = stub.Request.Body
+	if text, ok := stub.Request.Body.(string); stub.Request.Body != nil && (!ok || json.Valid([]byte(text))) {
+		body, err := compactJSON(stub.Request.Body)
 		if err != nil {
-			log.Error().Msgf("failed to marshal stub body: %v", err)
-			return []error{err}
+			s.log().Error().Msgf("failed to compact stub body: %v", err)
+			return []error{&FieldError{Field: "request.body", Err: err}}, nil
 		}
-		buff := new(bytes.Buffer)
-		if err = json.Compact(buff, bodyBytes); err != nil {
-			log.Error().Msgf("failed to compact stub body: %v", err)
-			return []error{err}
-		}
-		stub.Request.Body = buff.String()
+		stub.Request.Body = body
 	}
 
-	h := getHash(stub.Request)
-	if existing, ok := s.stubs[h]; ok {
-		log.Warn().Msgf("overriding existing stub: %s ", existing.Request.String())
+	stub.Request.compile()
+
+	if stub.ID == "" {
+		stub.ID = newUUID()
+		stub.generatedID = true
 	}
-	s.stubs[h] = stub
-	log.Info().Msgf("added stub: %s", stub.Request.String())
-	return []error{}
+	replaced, err := s.stubs.Add(stub)
+	if err != nil {
+		s.log().Error().Msgf("failed to store stub: %v", err)
+		return nil, &ErrStoreStub{ID: stub.ID, Err: err}
+	}
+	s.callsMu.Lock()
+	for _, existing := range replaced {
+		s.log().Warn().Msgf("overriding existing stub: %s ", existing.Request.String())
+		delete(s.calls, existing)
+	}
+	s.callsMu.Unlock()
+	s.log().Info().Msgf("added stub: %s", stub.Request.String())
+	return nil, nil
+}
+
+// findStub returns the stub that best matches the given request and its body,
+// along with the path parameters captured by it.
+func (s *Server) findStub(r *http.Request, body string) (*Stub, map[string]string, bool) {
+	return s.stubs.Match(r, body, s.scenarioState)
 }
 
-// addStubs adds multiple stubs to the server.
-func (s *Server) addStubs(stubs ...*Stub) {
-	for _, v := range stubs {
-		s.addStub(v)
+// addStubs adds multiple stubs to the server, returning the errors of the invalid ones.
+func (s *Server) addStubs(stubs ...*Stub) []error {
+	var errs []error
+	for i, v := range stubs {
+		stubErrs, err := s.addStub(v)
+		errs = append(errs, fieldErrors(fmt.Sprintf("stubs[%d]", i), stubErrs)...)
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errs
 }
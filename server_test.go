@@ -1,9 +1,19 @@
 package gmock
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewServer(t *testing.T) {
@@ -31,7 +41,7 @@ func TestNewServerWithConfig(t *testing.T) {
 			},
 			want: &Server{
 				port:  defaultPort,
-				stubs: make(stubHashMap),
+				stubs: &MemoryStubStore{stubs: make([]*Stub, 0)},
 			},
 		},
 		{
@@ -43,7 +53,7 @@ func TestNewServerWithConfig(t *testing.T) {
 			},
 			want: &Server{
 				port:  9999,
-				stubs: make(stubHashMap),
+				stubs: &MemoryStubStore{stubs: make([]*Stub, 0)},
 			},
 		},
 		{
@@ -53,6 +63,7 @@ func TestNewServerWithConfig(t *testing.T) {
 					Port: 9999,
 					Stubs: []*Stub{
 						{
+							ID: "test",
 							Request: StubRequest{
 								Method: "GET",
 								Path:   "/test",
@@ -67,8 +78,9 @@ func TestNewServerWithConfig(t *testing.T) {
 			},
 			want: &Server{
 				port: 9999,
-				stubs: stubHashMap{
-					hash("cf31ce76c1b07cfaecef83c3feb6c04dd2c98f4f2aa33375c7b7a7d2a90424da"): &Stub{
+				stubs: &MemoryStubStore{stubs: []*Stub{
+					{
+						ID: "test",
 						Request: StubRequest{
 							Method: "GET",
 							Path:   "/test",
@@ -78,7 +90,7 @@ func TestNewServerWithConfig(t *testing.T) {
 							Body:       "{}",
 						},
 					},
-				},
+				}},
 			},
 		},
 	}
@@ -86,7 +98,446 @@ func TestNewServerWithConfig(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			assert.Equal(t, tt.want.port, NewServerWithConfig(tt.args.config).port)
-			assert.Equal(t, tt.want.stubs, NewServerWithConfig(tt.args.config).stubs)
+			assert.Equal(t, tt.want.stubs.List(), NewServerWithConfig(tt.args.config).stubs.List())
+		})
+	}
+}
+
+func TestServer_findStub(t *testing.T) {
+	anonymous := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/test", Headers: map[string]Matcher{"Authorization": {Absent: true}}},
+		Response: StubResponse{StatusCode: http.StatusUnauthorized},
+	}
+	authorized := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/test", Headers: map[string]Matcher{"Authorization": {Matches: "^Bearer .+$"}}},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	tenant := &Stub{
+		Request: StubRequest{Method: http.MethodGet, Path: "/test", Headers: map[string]Matcher{
+			"Authorization": {Matches: "^Bearer .+$"},
+			"X-Tenant":      {EqualTo: "acme", CaseInsensitive: true},
+		}},
+		Response: StubResponse{StatusCode: http.StatusAccepted},
+	}
+	fallback := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/test"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	query := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/test", Query: url.Values{"id": []string{"1"}}},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	search := &Stub{
+		Request: StubRequest{Method: http.MethodGet, Path: "/test", QueryParams: map[string]Matcher{
+			"q": {Contains: "shady", CaseInsensitive: true},
+		}},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	body := &Stub{
+		Request:  StubRequest{Method: http.MethodPost, Path: "/test", Body: map[string]any{"name": "slim"}},
+		Response: StubResponse{StatusCode: http.StatusCreated},
+	}
+	user := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/{id}"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	me := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/me"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	file := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, PathPattern: `/files/(?P<name>[a-z]+)\.pdf`},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	form := &Stub{
+		Request:  StubRequest{Method: http.MethodPost, Path: "/login", FormParams: map[string]Matcher{"username": {EqualTo: "slim"}}},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	text := &Stub{
+		Request:  StubRequest{Method: http.MethodPut, Path: "/notes", Body: "hello world"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	s := NewServer().WithStubs(anonymous, authorized, tenant, fallback, query, search, body, user, me, file, form, text)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header http.Header
+		body   string
+		want   *Stub
+	}{
+		{
+			name:   "header absent",
+			method: http.MethodGet,
+			target: "/test",
+			want:   anonymous,
+		},
+		{
+			name:   "header matches",
+			method: http.MethodGet,
+			target: "/test",
+			header: http.Header{"Authorization": []string{"Bearer token"}},
+			want:   authorized,
+		},
+		{
+			name:   "most specific stub wins",
+			method: http.MethodGet,
+			target: "/test",
+			header: http.Header{"Authorization": []string{"Bearer token"}, "X-Tenant": []string{"ACME"}},
+			want:   tenant,
+		},
+		{
+			name:   "least specific stub as fallback",
+			method: http.MethodGet,
+			target: "/test",
+			header: http.Header{"Authorization": []string{"Basic xyz"}},
+			want:   fallback,
+		},
+		{
+			name:   "query equal to regardless of extra parameters",
+			method: http.MethodGet,
+			target: "/test?page=2&id=1",
+			header: http.Header{"Authorization": []string{"Basic xyz"}},
+			want:   query,
+		},
+		{
+			name:   "query param contains",
+			method: http.MethodGet,
+			target: "/test?q=Slim+Shady",
+			header: http.Header{"Authorization": []string{"Basic xyz"}},
+			want:   search,
+		},
+		{
+			name:   "body equal to",
+			method: http.MethodPost,
+			target: "/test",
+			body:   `{"name":"slim"}`,
+			want:   body,
+		},
+		{
+			name:   "body not equal to",
+			method: http.MethodPost,
+			target: "/test",
+			body:   `{"name":"shady"}`,
+		},
+		{
+			name:   "path template",
+			method: http.MethodGet,
+			target: "/v1/users/42",
+			want:   user,
+		},
+		{
+			name:   "exact path wins over path template",
+			method: http.MethodGet,
+			target: "/v1/users/me",
+			want:   me,
+		},
+		{
+			name:   "path pattern",
+			method: http.MethodGet,
+			target: "/files/report.pdf",
+			want:   file,
+		},
+		{
+			name:   "path pattern matches the whole path",
+			method: http.MethodGet,
+			target: "/files/report.pdf/download",
+		},
+		{
+			name:   "path pattern doesn't match a prefixed path",
+			method: http.MethodGet,
+			target: "/admin/files/report.pdf",
+		},
+		{
+			name:   "form params",
+			method: http.MethodPost,
+			target: "/login",
+			header: http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+			body:   "username=slim&password=shady",
+			want:   form,
+		},
+		{
+			name:   "form params without form content type",
+			method: http.MethodPost,
+			target: "/login",
+			body:   "username=slim&password=shady",
+		},
+		{
+			name:   "text body",
+			method: http.MethodPut,
+			target: "/notes",
+			body:   "hello world",
+			want:   text,
+		},
+		{
+			name:   "path not equal to",
+			method: http.MethodGet,
+			target: "/other",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			got, _, ok := s.findStub(r, tt.body)
+			assert.Equal(t, tt.want != nil, ok)
+			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestServer_loadStubs_bodyFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "users", "files"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users", "files", "users.json"), []byte(`[]`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users", "stub.yaml"), []byte(`
+request:
+  method: GET
+  path: /v1/users
+response:
+  status_code: 200
+  body_file: files/users.json
+`), 0o600))
+
+	// relative to the stub file, not to the files directory, and not loaded as a stub
+	s, err := NewServerWithConfigE(Config{StubsDir: dir, FilesDir: "fixtures"})
+	require.NoError(t, err)
+	require.Len(t, s.stubs.List(), 1)
+	assert.Equal(t, "files/users.json", s.stubs.List()[0].Response.BodyFile)
+	assert.Equal(t, filepath.Join(dir, "users", "files", "users.json"), s.stubs.List()[0].Response.bodyFilePath)
+}
+
+func TestServer_UpdateStub_RemoveStub(t *testing.T) {
+	stub := &Stub{
+		ID:       "get-user",
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/1"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	s := NewServer().WithStubs(stub)
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users/1", nil))
+	assert.Equal(t, []StubCalls{{Stub: stub, Calls: 1}}, s.Calls())
+
+	var notFound *ErrStubNotFound
+	assert.ErrorAs(t, s.UpdateStub("unknown", &Stub{}), &notFound)
+	var invalid *ErrInvalidStub
+	assert.ErrorAs(t, s.UpdateStub("get-user", &Stub{Request: StubRequest{Method: http.MethodGet}}), &invalid)
+
+	updated := &Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users/2"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}
+	require.NoError(t, s.UpdateStub("get-user", updated))
+	assert.Equal(t, "get-user", updated.ID)
+	got, ok := s.GetStub("get-user")
+	require.True(t, ok)
+	assert.Same(t, updated, got)
+	assert.Empty(t, s.calls)
+
+	require.NoError(t, s.RemoveStub("get-user"))
+	assert.Empty(t, s.Calls())
+	assert.ErrorAs(t, s.RemoveStub("get-user"), &notFound)
+}
+
+func TestServer_AddStub_ids(t *testing.T) {
+	s := NewServer()
+	for _, id := range []string{"a", "b", "", ""} {
+		s.AddStub(&Stub{
+			ID: id,
+			Request: StubRequest{
+				Method:  http.MethodGet,
+				Path:    "/v1/users/{id}",
+				Headers: map[string]Matcher{"Accept": {Matches: "json$"}},
+			},
+			Response: StubResponse{StatusCode: http.StatusOK},
+		})
+	}
+
+	// stubs with ids are only replaced by id, stubs without ids by request
+	stubs := s.stubs.List()
+	require.Len(t, stubs, 3)
+	assert.Equal(t, "a", stubs[0].ID)
+	assert.Equal(t, "b", stubs[1].ID)
+	_, ok := s.GetStub("a")
+	assert.True(t, ok)
+}
+
+func TestServer_LoadStubs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a_invalid.json":   "{\n  \"request\": {\n    \"method\": \"FETCH\",\n    \"path\": \"/v1/users\"\n  },\n  \"response\": {\"status_code\": 200}\n}\n",
+		"b_malformed.yaml": "request:\n  method: [GET\n",
+		"c_empty.yml":      "",
+		"d_valid.yaml":     "request:\n  method: GET\n  path: /v1/users\nresponse:\n  status_code: 200\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	s := NewServer()
+	err := s.LoadStubs(dir)
+	var stubsErr *ErrStubs
+	require.ErrorAs(t, err, &stubsErr)
+	require.Len(t, stubsErr.Errs, 3)
+	assert.EqualError(t, stubsErr.Errs[0], filepath.Join(dir, "a_invalid.json")+":3:15: request.method: method FETCH is not valid")
+	assert.Regexp(t, `^`+filepath.Join(dir, "b_malformed.yaml")+`:\d+: yaml: line \d+`, stubsErr.Errs[1].Error())
+	assert.EqualError(t, stubsErr.Errs[2], filepath.Join(dir, "c_empty.yml")+": stub is empty")
+
+	var invalidMethod *ErrInvalidMethod
+	assert.ErrorAs(t, stubsErr.Errs[0], &invalidMethod)
+	// invalid files don't prevent the following ones from being loaded
+	assert.Len(t, s.stubs.List(), 1)
+
+	assert.Error(t, s.LoadStubs(filepath.Join(dir, "unknown")))
+}
+
+func TestServer_AddStubE(t *testing.T) {
+	s := NewServer()
+	valid := &Stub{Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}, Response: StubResponse{StatusCode: http.StatusOK}}
+	require.NoError(t, s.AddStubE(valid))
+
+	var invalid *ErrInvalidStub
+	err := s.AddStubE(&Stub{Request: StubRequest{Path: "/v1/users"}})
+	require.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Errs, 2)
+	var required *ErrRequiredMethod
+	assert.ErrorAs(t, err, &required)
+	var field *FieldError
+	require.ErrorAs(t, s.UpdateStub(valid.ID, &Stub{Request: StubRequest{Method: "FETCH", Path: "/v1/users"}}), &field)
+	assert.Equal(t, "request.method", field.Field)
+
+	err = s.AddStubsE(valid, &Stub{Request: StubRequest{Method: http.MethodGet}, Response: StubResponse{StatusCode: http.StatusOK}})
+	var stubsErr *ErrStubs
+	require.ErrorAs(t, err, &stubsErr)
+	assert.Equal(t, []error{&FieldError{Field: "stubs[1].request.path", Err: &ErrRequiredPath{}}}, stubsErr.Errs)
+}
+
+func TestNewServerWithConfigE(t *testing.T) {
+	config := Config{
+		Stubs:    []*Stub{{Request: StubRequest{Method: http.MethodGet}, Response: StubResponse{StatusCode: http.StatusOK}}},
+		StubsDir: filepath.Join(t.TempDir(), "unknown"),
+	}
+	s, err := NewServerWithConfigE(config)
+	require.NotNil(t, s)
+	var stubsErr *ErrStubs
+	require.ErrorAs(t, err, &stubsErr)
+	assert.Len(t, stubsErr.Errs, 2)
+
+	assert.NotPanics(t, func() { NewServerWithConfig(config) })
+	config.Strict = true
+	assert.Panics(t, func() { NewServerWithConfig(config) })
+}
+
+func TestServer_StartE(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	s := NewServerWithConfig(Config{Port: l.Addr().(*net.TCPAddr).Port})
+	assert.Error(t, s.StartE())
+}
+
+func TestServer_Start(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	s := NewServer().WithPort(port).WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.WaitReady(ctx), context.DeadlineExceeded)
+
+	require.NoError(t, s.Start())
+	defer s.Stop() // nolint:errcheck
+	select {
+	case <-s.Ready():
+	default:
+		t.Fatal("server not ready after start")
+	}
+	require.NoError(t, s.WaitReady(context.Background()))
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/v1/users", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the port is already bound
+	assert.Error(t, NewServer().WithPort(port).Start())
+}
+
+func TestServer_Start_randomPort(t *testing.T) {
+	s := NewServer().WithPort(0)
+	assert.Nil(t, s.Addr())
+	assert.Empty(t, s.URL())
+
+	require.NoError(t, s.Start())
+	defer s.Stop() // nolint:errcheck
+	addr, ok := s.Addr().(*net.TCPAddr)
+	require.True(t, ok)
+	assert.NotZero(t, addr.Port)
+	assert.Equal(t, fmt.Sprintf("http://127.0.0.1:%d", addr.Port), s.URL())
+
+	resp, err := http.Get(s.URL() + "/httpmock/list")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewServerWithConfig_randomPort(t *testing.T) {
+	servers := make([]*Server, 2)
+	for i := range servers {
+		servers[i] = NewServerWithConfig(Config{RandomPort: true})
+		require.NoError(t, servers[i].Start())
+		defer servers[i].Stop() // nolint:errcheck
+	}
+	assert.NotEqual(t, servers[0].URL(), servers[1].URL())
+
+	resp, err := http.Get(servers[1].URL() + "/httpmock/list")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_WithListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := NewServerWithConfig(Config{Listener: l})
+	require.NoError(t, s.Start())
+	defer s.Stop() // nolint:errcheck
+	assert.Equal(t, l.Addr(), s.Addr())
+	assert.Equal(t, "http://"+l.Addr().String(), s.URL())
+}
+
+func TestServer_WithListener_unix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "gmock.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	s := NewServer().WithListener(l).WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	})
+	require.NoError(t, s.Start())
+	defer s.Stop() // nolint:errcheck
+	assert.Equal(t, "unix", s.Addr().Network())
+	assert.Empty(t, s.URL())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://gmock/v1/users")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,142 @@
+package gmock // nolint:golint
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// StubStore stores the stubs of a server. Implementations must be safe for concurrent use.
+type StubStore interface {
+	// Add adds a stub, replacing the stub with the same id (if any), which is returned.
+	// A stub with a generated id also replaces the stubs with generated ids matching the same requests.
+	Add(stub *Stub) ([]*Stub, error)
+	// Remove removes the stub with the given id.
+	Remove(id string) error
+	// Get returns the stub with the given id.
+	Get(id string) (*Stub, bool)
+	// List returns the stubs, in the order they were added. The returned slice must not be modified.
+	List() []*Stub
+	// Match returns the stub that best matches the given request and its body,
+	// along with the path parameters captured by it. state returns the current state of a scenario.
+	Match(r *http.Request, body string, state func(scenario string) string) (*Stub, map[string]string, bool)
+	// Clear removes every stub.
+	Clear() error
+}
+
+// MemoryStubStore is a StubStore keeping stubs in memory.
+// Writers replace the list of stubs instead of modifying it (copy-on-write),
+// so the snapshots returned by List can be read without holding the lock.
+type MemoryStubStore struct {
+	mu    sync.RWMutex
+	stubs []*Stub
+}
+
+// NewMemoryStubStore creates an empty in-memory stub store.
+func NewMemoryStubStore() *MemoryStubStore {
+	return &MemoryStubStore{stubs: make([]*Stub, 0)}
+}
+
+// Add adds a stub to the store, replacing the stub with the same id (if any), which is returned.
+// A stub with a generated id also replaces the stubs with generated ids matching the same requests,
+// so reloading stubs without ids doesn't duplicate them. The stub takes the place of the first stub it replaces.
+func (st *MemoryStubStore) Add(stub *Stub) ([]*Stub, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stubs := make([]*Stub, 0, len(st.stubs)+1)
+	var replaced []*Stub
+	for _, existing := range st.stubs {
+		if existing.ID == stub.ID || (stub.generatedID && existing.generatedID && sameRequest(existing, stub)) {
+			if len(replaced) == 0 {
+				stubs = append(stubs, stub)
+			}
+			replaced = append(replaced, existing)
+			continue
+		}
+		stubs = append(stubs, existing)
+	}
+	if len(replaced) == 0 {
+		stubs = append(stubs, stub)
+	}
+	st.stubs = stubs
+	return replaced, nil
+}
+
+// Remove removes the stub with the given id from the store.
+func (st *MemoryStubStore) Remove(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i, stub := range st.stubs {
+		if stub.ID == id {
+			stubs := make([]*Stub, 0, len(st.stubs)-1)
+			stubs = append(stubs, st.stubs[:i]...)
+			st.stubs = append(stubs, st.stubs[i+1:]...)
+			return nil
+		}
+	}
+	return &ErrStubNotFound{ID: id}
+}
+
+// Get returns the stub with the given id.
+func (st *MemoryStubStore) Get(id string) (*Stub, bool) {
+	for _, stub := range st.List() {
+		if stub.ID == id {
+			return stub, true
+		}
+	}
+	return nil, false
+}
+
+// List returns a snapshot of the stubs of the store, which must not be modified.
+func (st *MemoryStubStore) List() []*Stub {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.stubs
+}
+
+// Match returns the stub of the store that best matches the given request and its body.
+func (st *MemoryStubStore) Match(r *http.Request, body string, state func(string) string) (*Stub, map[string]string, bool) {
+	return matchStubs(st.List(), r, body, state)
+}
+
+// Clear removes every stub from the store.
+func (st *MemoryStubStore) Clear() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.stubs = make([]*Stub, 0)
+	return nil
+}
+
+// sameRequest reports whether the stubs match the same requests in the same scenario states.
+// The compiled expressions of requests are compared too, they're equal when compiled from equal requests.
+func sameRequest(a, b *Stub) bool {
+	return reflect.DeepEqual(a.Request, b.Request) &&
+		a.Scenario == b.Scenario && a.RequiredState == b.RequiredState && a.NewState == b.NewState
+}
+
+// matchStubs returns the stub that best matches the given request and its body,
+// along with the path parameters captured by it.
+// The best match is the stub satisfying the most predicates,
+// ties are resolved in favor of the most recently added stub.
+func matchStubs(stubs []*Stub, r *http.Request, body string, state func(string) string) (*Stub, map[string]string, bool) {
+	b := newRequestBody(r.Header.Get("Content-Type"), []byte(body))
+	var found *Stub
+	var params map[string]string
+	best := 0
+	for _, stub := range stubs {
+		if !stub.matchScenario(state) {
+			continue
+		}
+		score, p, ok := stub.Request.match(r, b)
+		// stubs requiring a scenario state are more specific
+		if stub.RequiredState != "" {
+			score++
+		}
+		if ok && score >= best {
+			found = stub
+			params = p
+			best = score
+		}
+	}
+	return found, params, found != nil
+}
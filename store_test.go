@@ -0,0 +1,114 @@
+package gmock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStubStore(t *testing.T) {
+	st := NewMemoryStubStore()
+	first := &Stub{ID: "1", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}}
+	other := &Stub{ID: "2", Request: StubRequest{Method: http.MethodPost, Path: "/v1/users"}}
+	second := &Stub{ID: "3", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}}
+	updated := &Stub{ID: "2", Request: StubRequest{Method: http.MethodPut, Path: "/v1/users/{id}"}}
+
+	for _, stub := range []*Stub{first, other} {
+		replaced, err := st.Add(stub)
+		require.NoError(t, err)
+		assert.Empty(t, replaced)
+	}
+	snapshot := st.List()
+
+	// same request, different id
+	replaced, err := st.Add(second)
+	require.NoError(t, err)
+	assert.Empty(t, replaced)
+	// same id
+	replaced, err = st.Add(updated)
+	require.NoError(t, err)
+	assert.Equal(t, []*Stub{other}, replaced)
+	assert.Equal(t, []*Stub{first, updated, second}, st.List())
+	// snapshots aren't affected by later changes
+	assert.Equal(t, []*Stub{first, other}, snapshot)
+
+	got, ok := st.Get("3")
+	assert.True(t, ok)
+	assert.Same(t, second, got)
+	got, ok = st.Get("1")
+	assert.True(t, ok)
+	assert.Same(t, first, got)
+	_, ok = st.Get("4")
+	assert.False(t, ok)
+
+	stub, _, ok := st.Match(httptest.NewRequest(http.MethodPut, "/v1/users/42", nil), "", func(string) string { return ScenarioStarted })
+	assert.True(t, ok)
+	assert.Same(t, updated, stub)
+
+	require.NoError(t, st.Remove("3"))
+	assert.Equal(t, []*Stub{first, updated}, st.List())
+	var notFound *ErrStubNotFound
+	assert.ErrorAs(t, st.Remove("3"), &notFound)
+
+	require.NoError(t, st.Clear())
+	assert.Empty(t, st.List())
+}
+
+func TestMemoryStubStore_Add_generatedID(t *testing.T) {
+	st := NewMemoryStubStore()
+	named := &Stub{ID: "users", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}}
+	first := &Stub{ID: "a", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}, generatedID: true}
+	scenario := &Stub{ID: "b", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}, generatedID: true, Scenario: "users", RequiredState: "created"}
+	for _, stub := range []*Stub{named, first, scenario} {
+		replaced, err := st.Add(stub)
+		require.NoError(t, err)
+		assert.Empty(t, replaced)
+	}
+
+	// only replaces the stub with a generated id matching the same requests in the same scenario states
+	second := &Stub{ID: "c", Request: StubRequest{Method: http.MethodGet, Path: "/v1/users"}, generatedID: true}
+	replaced, err := st.Add(second)
+	require.NoError(t, err)
+	assert.Equal(t, []*Stub{first}, replaced)
+	assert.Equal(t, []*Stub{named, second, scenario}, st.List())
+}
+
+// TestServer_concurrentStubs adds, lists, matches and clears stubs concurrently, run it with -race.
+func TestServer_concurrentStubs(t *testing.T) {
+	s := NewServer()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			s.AddStub(&Stub{
+				Request:  StubRequest{Method: http.MethodGet, Path: fmt.Sprintf("/v1/users/%d", i)},
+				Response: StubResponse{StatusCode: http.StatusOK},
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			s.listStubsHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/httpmock/list", nil))
+			s.Calls()
+			s.Scenarios()
+		}()
+		go func() {
+			defer wg.Done()
+			s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/users/%d", i), nil))
+		}()
+		go func() {
+			defer wg.Done()
+			if i%5 == 0 {
+				s.ClearStubs()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, len(s.stubs.List()), 10)
+}
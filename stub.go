@@ -1,64 +1,164 @@
 package gmock // nolint:golint
 
 import (
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
 	"net/url"
-
-	"gopkg.in/yaml.v3"
+	"regexp"
 )
 
-// hash is a string that uniquely identifies a stub.
-type hash string
-
-// stubHashMap is a map of hash to stub.
-type stubHashMap map[hash]*Stub
-
 // Stub is a request and response pair that is used to match incoming requests.
+// ID identifies the stub, a random UUID is generated when it's empty.
+// Adding a stub replaces the stub with the same ID, or the stubs without ID matching the same requests when it has none.
+// Responses is a sequence of responses served in order instead of Response, one per call to the stub.
+// Once they've all been served, AfterExhausted sets whether the last one is repeated (the default),
+// the sequence starts over or a 404 Not Found is returned.
+// A stub of a Scenario only matches when the scenario is in RequiredState (if set),
+// and moves the scenario to NewState (if set) when served. Scenarios start in the Started state.
 type Stub struct {
-	Request  StubRequest  `json:"request" yaml:"request"`
-	Response StubResponse `json:"response" yaml:"response"`
+	ID             string          `json:"id,omitempty" yaml:"id,omitempty"`
+	Request        StubRequest     `json:"request" yaml:"request"`
+	Response       StubResponse    `json:"response" yaml:"response"`
+	Responses      []StubResponse  `json:"responses,omitempty" yaml:"responses,omitempty"`
+	AfterExhausted ExhaustedPolicy `json:"after_exhausted,omitempty" yaml:"after_exhausted,omitempty"`
+	Scenario       string          `json:"scenario,omitempty" yaml:"scenario,omitempty"`
+	RequiredState  string          `json:"required_state,omitempty" yaml:"required_state,omitempty"`
+	NewState       string          `json:"new_state,omitempty" yaml:"new_state,omitempty"`
+
+	source       string // the file the stub was loaded from, if any
+	generatedID  bool   // whether the id was generated when the stub was added
+	receivedBody any    // the request body as received, before it's compacted
 }
 
 // StubRequest is the request part of a Stub.
+// Method, Path, Query and Body must be equal to the ones of the incoming request,
+// while QueryParams, Headers and BodyMatcher are predicates evaluated against it.
+// Fields left empty are not taken into account when matching.
+//
+// Path can be a template with {name} parameters (e.g. /v1/users/{id}),
+// * single-segment wildcards (e.g. /files/*) and ** multi-segment wildcards (e.g. /static/**).
+// PathPattern is a regular expression matched against the whole request path instead of Path,
+// where named groups (e.g. (?P<id>[0-9]+)) are captured as parameters.
+// Captured parameters replace the ${path.name} placeholders of the stub response headers and body.
+//
+// Body is compared to the incoming JSON body (sent with a JSON Content-Type, or without one)
+// regardless of whitespace and key order, while a string Body is compared as is to incoming bodies
+// that aren't JSON (e.g. plain text).
+// With PartialBody, fields of the incoming body that are not in Body are ignored,
+// and with IgnoreArrayOrder, arrays match regardless of the order of their elements.
+// Values of Body can be ${json-unit.any-string}, ${json-unit.any-number}, ${json-unit.any-boolean}
+// or ${json-unit.ignore} placeholders to match any value of the given type (or any value at all).
+// BodyMatcher is evaluated against the raw incoming body (e.g. plain text equality or regex),
+// FormParams against the fields of url-encoded and multipart form bodies,
+// and BodyPatterns are predicates such as JSONPath and XPath expressions that the incoming body must all satisfy
+// (Base64 and SHA256 patterns are evaluated against the raw incoming body too).
 type StubRequest struct {
-	Method string     `json:"method" yaml:"method"`
-	Path   string     `json:"path" yaml:"path"`
-	Query  url.Values `json:"query" yaml:"query"`
-	Body   any        `json:"body" yaml:"body"`
+	Method           string             `json:"method" yaml:"method"`
+	Path             string             `json:"path" yaml:"path"`
+	PathPattern      string             `json:"path_pattern,omitempty" yaml:"path_pattern,omitempty"`
+	Query            url.Values         `json:"query" yaml:"query"`
+	Body             any                `json:"body" yaml:"body"`
+	PartialBody      bool               `json:"partial_body,omitempty" yaml:"partial_body,omitempty"`
+	IgnoreArrayOrder bool               `json:"ignore_array_order,omitempty" yaml:"ignore_array_order,omitempty"`
+	QueryParams      map[string]Matcher `json:"query_params,omitempty" yaml:"query_params,omitempty"`
+	Headers          map[string]Matcher `json:"headers,omitempty" yaml:"headers,omitempty"`
+	BodyMatcher      *Matcher           `json:"body_matcher,omitempty" yaml:"body_matcher,omitempty"`
+	FormParams       map[string]Matcher `json:"form_params,omitempty" yaml:"form_params,omitempty"`
+	BodyPatterns     []BodyPattern      `json:"body_patterns,omitempty" yaml:"body_patterns,omitempty"`
+
+	pathRegexp *regexp.Regexp // the compiled path pattern or path template, see compile
 }
 
 // StubResponse is the response part of a Stub.
+// BodyMode sets how Body is written (json, raw or base64), it's inferred from the Content-Type header when empty.
+// BodyFile is a file streamed as the body instead of Body, relative to the directory of the stub file
+// (or to the files directory of the server for stubs that aren't loaded from files).
+// Body files can be kept next to stub files in files directories, which aren't loaded as stubs.
+// With Template, the strings of Headers and Body are executed as Go text/template templates
+// with the incoming request data (Method, Path, PathParams, Query, Headers and Body)
+// and helpers such as pathParam, query, header, jsonPath, now, formatDate, uuid and randomInt.
+// Delay delays the response, on top of the delay of the server.
+// Fault writes a broken response (e.g. a connection reset) instead of the regular response.
 type StubResponse struct {
 	StatusCode int                 `json:"status_code" yaml:"status_code"`
 	Headers    map[string][]string `json:"headers" yaml:"headers"`
 	Body       any                 `json:"body" yaml:"body"`
+	BodyMode   BodyMode            `json:"body_mode,omitempty" yaml:"body_mode,omitempty"`
+	BodyFile   string              `json:"body_file,omitempty" yaml:"body_file,omitempty"`
+	Template   bool                `json:"template,omitempty" yaml:"template,omitempty"`
+	Delay      *Delay              `json:"delay,omitempty" yaml:"delay,omitempty"`
+	Fault      Fault               `json:"fault,omitempty" yaml:"fault,omitempty"`
+
+	bodyFilePath string // BodyFile resolved against the directory it's relative to
 }
 
 // Validate returns a list of validation errors for the current stub request.
 func (r *StubRequest) Validate() []error {
 	var errs []error
 	if r.Method == "" {
-		errs = append(errs, &errRequiredMethod{})
+		errs = append(errs, &FieldError{Field: "method", Err: &ErrRequiredMethod{}})
 	} else if _, ok := httpMethods[r.Method]; !ok {
-		errs = append(errs, &errInvalidMethod{method: r.Method})
+		errs = append(errs, &FieldError{Field: "method", Err: &ErrInvalidMethod{Method: r.Method}})
+	}
+	if r.Path == "" && r.PathPattern == "" {
+		errs = append(errs, &FieldError{Field: "path", Err: &ErrRequiredPath{}})
+	}
+	if r.PathPattern != "" {
+		if _, err := pathPatternRegexp(r.PathPattern); err != nil {
+			errs = append(errs, &FieldError{Field: "path_pattern", Err: &ErrInvalidPattern{Pattern: r.PathPattern, Err: err}})
+		}
+	} else if isPathTemplate(r.Path) {
+		if _, err := pathTemplateRegexp(r.Path); err != nil {
+			errs = append(errs, &FieldError{Field: "path", Err: &ErrInvalidPattern{Pattern: r.Path, Err: err}})
+		}
 	}
-	if r.Path == "" {
-		errs = append(errs, &errRequiredPath{})
+	for _, k := range sortedKeys(r.QueryParams) {
+		m := r.QueryParams[k]
+		errs = append(errs, fieldErrors("query_params."+k, m.Validate())...)
+	}
+	for _, k := range sortedKeys(r.Headers) {
+		m := r.Headers[k]
+		errs = append(errs, fieldErrors("headers."+k, m.Validate())...)
+	}
+	if r.BodyMatcher != nil {
+		errs = append(errs, fieldErrors("body_matcher", r.BodyMatcher.Validate())...)
+	}
+	for _, k := range sortedKeys(r.FormParams) {
+		m := r.FormParams[k]
+		errs = append(errs, fieldErrors("form_params."+k, m.Validate())...)
+	}
+	for i, p := range r.BodyPatterns {
+		errs = append(errs, fieldErrors(fmt.Sprintf("body_patterns[%d]", i), p.Validate())...)
 	}
 	return errs
 }
 
 // Sanitize sanitizes a stub request.
+// Empty maps are dropped, so that a request decoded from a stub file equals the one it was written from.
 func (r *StubRequest) Sanitize() {
 	if r.Path != "" && r.Path[0] != '/' {
 		r.Path = "/" + r.Path
 	}
+	if len(r.Query) == 0 {
+		r.Query = nil
+	}
+	if len(r.QueryParams) == 0 {
+		r.QueryParams = nil
+	}
+	if len(r.Headers) == 0 {
+		r.Headers = nil
+	}
+	if len(r.FormParams) == 0 {
+		r.FormParams = nil
+	}
 }
 
 // String returns a string representation of the stub request.
 func (r *StubRequest) String() string {
 	_url := r.Path
+	if _url == "" {
+		_url = r.PathPattern
+	}
 	first := true
 	// fixme: write existing multiple values for the same key in query
 	for k := range r.Query {
@@ -70,6 +170,9 @@ func (r *StubRequest) String() string {
 		}
 		_url += fmt.Sprintf("%s=%s", k, r.Query.Get(k))
 	}
+	if r.Body == nil {
+		return fmt.Sprintf(`%s %s`, r.Method, _url)
+	}
 	return fmt.Sprintf(`%s %s %s`, r.Method, _url, r.Body)
 }
 
@@ -77,23 +180,75 @@ func (r *StubRequest) String() string {
 func (r *StubResponse) Validate() []error {
 	var errs []error
 	if r.StatusCode < 200 || r.StatusCode > 599 {
-		errs = append(errs, &errInvalidStatusCode{statusCode: r.StatusCode})
+		errs = append(errs, &FieldError{Field: "status_code", Err: &ErrInvalidStatusCode{StatusCode: r.StatusCode}})
+	}
+	if r.Body != nil && r.BodyFile != "" {
+		errs = append(errs, &FieldError{Field: "body", Err: &ErrConflictingBody{}})
+	}
+	switch r.BodyMode {
+	case "", BodyModeJSON:
+	case BodyModeRaw, BodyModeBase64:
+		if _, ok := r.Body.(string); r.Body != nil && !ok {
+			errs = append(errs, &FieldError{Field: "body_mode", Err: &ErrInvalidBodyMode{BodyMode: r.BodyMode}})
+		}
+	default:
+		errs = append(errs, &FieldError{Field: "body_mode", Err: &ErrInvalidBodyMode{BodyMode: r.BodyMode}})
+	}
+	if s, ok := r.Body.(string); ok && r.bodyMode() == BodyModeBase64 {
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			errs = append(errs, &FieldError{Field: "body", Err: &ErrInvalidBase64Body{Err: err}})
+		}
+	}
+	if r.Template {
+		errs = append(errs, r.templateErrors()...)
+	}
+	if r.Delay != nil {
+		errs = append(errs, fieldErrors("delay", r.Delay.Validate())...)
+	}
+	if _, ok := faults[r.Fault]; r.Fault != "" && !ok {
+		errs = append(errs, &FieldError{Field: "fault", Err: &ErrInvalidFault{Fault: r.Fault}})
 	}
 	return errs
 }
 
 // validationErrors returns a list of validation errors for the current stub.
 func (r *Stub) validationErrors() []error {
-	return append(r.Request.Validate(), r.Response.Validate()...)
+	errs := fieldErrors("request", r.Request.Validate())
+	if r.Scenario == "" && (r.RequiredState != "" || r.NewState != "") {
+		errs = append(errs, &FieldError{Field: "scenario", Err: &ErrRequiredScenario{}})
+	}
+	if len(r.Responses) == 0 {
+		return append(errs, fieldErrors("response", r.Response.Validate())...)
+	}
+	for i := range r.Responses {
+		errs = append(errs, fieldErrors(fmt.Sprintf("responses[%d]", i), r.Responses[i].Validate())...)
+	}
+	switch r.AfterExhausted {
+	case "", ExhaustedRepeatLast, ExhaustedCycle, ExhaustedNotFound:
+	default:
+		errs = append(errs, &FieldError{Field: "after_exhausted", Err: &ErrInvalidExhaustedPolicy{Policy: r.AfterExhausted}})
+	}
+	return errs
 }
 
-// getStubFromBytest returns a stub from a byte array.
-func getStubFromBytes(b []byte) (*Stub, error) {
-	var stub *Stub
-	if err := json.Unmarshal(b, stub); err != nil {
-		if err := yaml.Unmarshal(b, &stub); err != nil {
-			return nil, err
+// bodyFileErrors returns the errors of the body files of a stub received through the admin API,
+// which must stay inside the files directory of the server.
+func (r *Stub) bodyFileErrors() []error {
+	var errs []error
+	if r.Response.BodyFile != "" && !isLocalPath(r.Response.BodyFile) {
+		errs = append(errs, &FieldError{Field: "response.body_file", Err: &ErrUnsafeBodyFile{BodyFile: r.Response.BodyFile}})
+	}
+	for i, response := range r.Responses {
+		if response.BodyFile != "" && !isLocalPath(response.BodyFile) {
+			field := fmt.Sprintf("responses[%d].body_file", i)
+			errs = append(errs, &FieldError{Field: field, Err: &ErrUnsafeBodyFile{BodyFile: response.BodyFile}})
 		}
 	}
-	return stub, nil
+	return errs
+}
+
+// getStubFromBytes returns a stub from a JSON or YAML byte array.
+func getStubFromBytes(b []byte) (*Stub, error) {
+	stub, _, err := decodeStub(b)
+	return stub, err
 }
@@ -1,11 +1,15 @@
 package gmock
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestStubRequest_Validate(t *testing.T) {
@@ -34,7 +38,7 @@ func TestStubRequest_Validate(t *testing.T) {
 			fields: fields{
 				Path: "/test",
 			},
-			want: []error{&errRequiredMethod{}},
+			want: []error{&FieldError{Field: "method", Err: &ErrRequiredMethod{}}},
 		},
 		{
 			name: "invalid method",
@@ -42,7 +46,7 @@ func TestStubRequest_Validate(t *testing.T) {
 				Method: "INVALID",
 				Path:   "/test",
 			},
-			want: []error{&errInvalidMethod{method: "INVALID"}},
+			want: []error{&FieldError{Field: "method", Err: &ErrInvalidMethod{Method: "INVALID"}}},
 		},
 		{
 			name: "invalid path",
@@ -50,7 +54,7 @@ func TestStubRequest_Validate(t *testing.T) {
 				Method: http.MethodGet,
 				Path:   "",
 			},
-			want: []error{&errRequiredPath{}},
+			want: []error{&FieldError{Field: "path", Err: &ErrRequiredPath{}}},
 		},
 	}
 	for _, tt := range tests {
@@ -78,6 +82,24 @@ func TestStubRequest_Sanitize(t *testing.T) {
 	}
 	r.Sanitize()
 	assert.Equal(t, "/test", r.Path)
+	r = &StubRequest{
+		Path:    "/test",
+		Query:   url.Values{},
+		Headers: map[string]Matcher{},
+	}
+	r.Sanitize()
+	assert.Nil(t, r.Query)
+	assert.Nil(t, r.Headers)
+}
+
+func TestStubRequest_marshal(t *testing.T) {
+	r := StubRequest{Method: http.MethodGet, Path: "/test"}
+	b, err := json.Marshal(r)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), `"headers"`)
+	b, err = yaml.Marshal(r)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "headers:")
 }
 
 func TestStubResponse_Validate(t *testing.T) {
@@ -103,7 +125,25 @@ func TestStubResponse_Validate(t *testing.T) {
 			fields: fields{
 				StatusCode: -1,
 			},
-			want: []error{&errInvalidStatusCode{statusCode: -1}},
+			want: []error{&FieldError{Field: "status_code", Err: &ErrInvalidStatusCode{StatusCode: -1}}},
+		},
+		{
+			name: "form body",
+			fields: fields{
+				StatusCode: 200,
+				Headers:    map[string][]string{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:       "a=b&c=d",
+			},
+			want: nil,
+		},
+		{
+			name: "invalid base64 body",
+			fields: fields{
+				StatusCode: 200,
+				Headers:    map[string][]string{"Content-Type": {"application/pdf"}},
+				Body:       "not base64!",
+			},
+			want: []error{&FieldError{Field: "body", Err: &ErrInvalidBase64Body{Err: base64.CorruptInputError(3)}}},
 		},
 	}
 	for _, tt := range tests {
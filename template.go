@@ -0,0 +1,142 @@
+package gmock // nolint:golint
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// incomingRequest is an incoming request that matched a stub,
+// along with its body (compacted when JSON) and the path parameters captured by the stub.
+type incomingRequest struct {
+	r      *http.Request
+	body   string
+	params map[string]string
+}
+
+// templateData is the data available to stub response templates.
+type templateData struct {
+	Method     string
+	Path       string
+	PathParams map[string]string
+	Query      url.Values
+	Headers    http.Header
+	Body       string
+}
+
+// templateFuncs returns the helpers available to stub response templates:
+//
+//	pathParam "name"          the path parameter captured by the stub request
+//	query "name"              the first value of the query parameter
+//	header "name"             the first value of the header
+//	jsonPath "$.path"         the first value selected by the JSONPath in the JSON body (empty when none)
+//	now ["layout"]            the current time, formatted with the layout (RFC 3339 by default)
+//	formatDate "layout" date  the RFC 3339 date formatted with the layout
+//	uuid                      a random UUID
+//	randomInt min max         a random integer in [min, max]
+func templateFuncs(in *incomingRequest) template.FuncMap {
+	return template.FuncMap{
+		"pathParam": func(name string) string {
+			return in.params[name]
+		},
+		"query": func(name string) string {
+			return in.r.URL.Query().Get(name)
+		},
+		"header": func(name string) string {
+			return in.r.Header.Get(name)
+		},
+		"jsonPath": func(path string) (any, error) {
+			selectors, err := parseJSONPath(path)
+			if err != nil {
+				return nil, err
+			}
+			doc, err := decodeJSON(in.body)
+			if err != nil {
+				return "", nil
+			}
+			values := selectJSONPath(doc, selectors)
+			if len(values) == 0 {
+				return "", nil
+			}
+			return values[0], nil
+		},
+		"now": func(layout ...string) string {
+			if len(layout) > 0 {
+				return time.Now().Format(layout[0])
+			}
+			return time.Now().Format(time.RFC3339)
+		},
+		"formatDate": func(layout, date string) (string, error) {
+			t, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return "", err
+			}
+			return t.Format(layout), nil
+		},
+		"uuid": newUUID,
+		"randomInt": func(min, max int64) (int64, error) {
+			if max < min {
+				return 0, fmt.Errorf("max %d is lower than min %d", max, min)
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(max-min+1))
+			if err != nil {
+				return 0, err
+			}
+			return min + n.Int64(), nil
+		},
+	}
+}
+
+// parseTemplate parses a stub response template.
+func parseTemplate(s string, in *incomingRequest) (*template.Template, error) {
+	return template.New("").Option("missingkey=zero").Funcs(templateFuncs(in)).Parse(s)
+}
+
+// renderTemplate executes the given stub response template with the incoming request data.
+func renderTemplate(s string, in *incomingRequest) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	t, err := parseTemplate(s, in)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = t.Execute(&b, templateData{
+		Method:     in.r.Method,
+		Path:       in.r.URL.Path,
+		PathParams: in.params,
+		Query:      in.r.URL.Query(),
+		Headers:    in.r.Header,
+		Body:       in.body,
+	})
+	return b.String(), err
+}
+
+// templateErrors returns the errors parsing the templates of the stub response headers and body.
+func (r *StubResponse) templateErrors() []error {
+	var errs []error
+	field := ""
+	validate := func(s string) (string, error) {
+		if _, err := parseTemplate(s, &incomingRequest{}); err != nil {
+			errs = append(errs, &FieldError{Field: field, Err: &ErrInvalidTemplate{Template: s, Err: err}})
+		}
+		return s, nil
+	}
+	for _, k := range sortedKeys(r.Headers) {
+		field = "headers." + k
+		for _, v := range r.Headers[k] {
+			_, _ = validate(v)
+		}
+	}
+	if r.bodyMode() != BodyModeBase64 {
+		field = "body"
+		_, _ = mapStrings(r.Body, validate)
+	}
+	return errs
+}
@@ -0,0 +1,98 @@
+package gmock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderTemplate(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/42?expand=orders", strings.NewReader(`{"order":{"id":"A-1"}}`))
+	r.Header.Set("X-Correlation-Id", "abc-123")
+	in := &incomingRequest{r: r, body: `{"order":{"id":"A-1"}}`, params: map[string]string{"id": "42"}}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "no template", template: "plain", want: "plain"},
+		{name: "request data", template: "{{ .Method }} {{ .Path }} {{ .PathParams.id }}", want: "POST /v1/users/42 42"},
+		{name: "path param", template: `{{ pathParam "id" }}`, want: "42"},
+		{name: "query", template: `{{ query "expand" }}`, want: "orders"},
+		{name: "header", template: `{{ header "X-Correlation-Id" }}`, want: "abc-123"},
+		{name: "json path", template: `{{ jsonPath "$.order.id" }}`, want: "A-1"},
+		{name: "missing json path", template: `{{ jsonPath "$.order.sku" }}`, want: ""},
+		{name: "format date", template: `{{ formatDate "2006-01-02" "2022-08-01T10:00:00Z" }}`, want: "2022-08-01"},
+		{name: "invalid date", template: `{{ formatDate "2006-01-02" "yesterday" }}`, wantErr: true},
+		{name: "invalid template", template: `{{ pathParam }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate(tt.template, in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_renderTemplate_generated(t *testing.T) {
+	in := &incomingRequest{r: httptest.NewRequest(http.MethodGet, "/", nil)}
+
+	got, err := renderTemplate(`{{ now "2006" }}`, in)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(time.Now().Year()), got)
+
+	got, err = renderTemplate(`{{ uuid }}`, in)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got)
+
+	got, err = renderTemplate(`{{ randomInt 1 3 }}`, in)
+	require.NoError(t, err)
+	assert.Contains(t, []string{"1", "2", "3"}, got)
+
+	_, err = renderTemplate(`{{ randomInt 3 1 }}`, in)
+	assert.Error(t, err)
+}
+
+func TestStubResponse_templateErrors(t *testing.T) {
+	r := &StubResponse{
+		StatusCode: http.StatusOK,
+		Template:   true,
+		Headers:    http.Header{"X-Correlation-Id": {`{{ header "X-Correlation-Id" }}`}},
+		Body:       map[string]any{"id": `{{ pathParam "id" }}`},
+	}
+	assert.Nil(t, r.Validate())
+
+	r.Body = map[string]any{"id": `{{ pathParam "id" }`}
+	assert.Len(t, r.Validate(), 1)
+}
+
+func TestStubResponse_write_template(t *testing.T) {
+	r := &StubResponse{
+		StatusCode: http.StatusOK,
+		Template:   true,
+		Headers:    http.Header{"X-Correlation-Id": {`{{ header "X-Correlation-Id" }}`}},
+		Body:       map[string]any{"id": `{{ pathParam "id" }}`, "path": "${path.id}"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/42", nil)
+	req.Header.Set("X-Correlation-Id", "abc-123")
+
+	w := httptest.NewRecorder()
+	require.NoError(t, r.write(w, &incomingRequest{r: req, params: map[string]string{"id": "42"}}))
+	assert.Equal(t, "abc-123", w.Header().Get("X-Correlation-Id"))
+	assert.JSONEq(t, `{"id":"42","path":"42"}`, w.Body.String())
+}
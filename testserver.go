@@ -0,0 +1,39 @@
+package gmock // nolint:golint
+
+import (
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// NewTestServer starts a server with the given stubs for the test, on a loopback port chosen by the system (see Server.URL).
+// Its logs are written to the test log and it's stopped once the test and its subtests complete,
+// failing the test if it received requests that didn't match any stub or if any stub was never served.
+// Unlike Start, it doesn't load the stubs of the default stubs directory.
+func NewTestServer(t testing.TB, stubs ...*Stub) *Server {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("failed to start HTTP mock server: " + err.Error())
+	}
+	w := zerolog.NewConsoleWriter(zerolog.ConsoleTestWriter(t), func(w *zerolog.ConsoleWriter) { w.NoColor = true })
+	s := NewServer().WithListener(l).WithLogger(zerolog.New(w).With().Timestamp().Logger())
+	s.dir = ""
+	if err := s.AddStubsE(stubs...); err != nil {
+		l.Close() // nolint:errcheck
+		t.Fatal(err)
+	}
+	if err := s.StartE(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := s.Stop(); err != nil {
+			t.Error("failed to stop HTTP mock server: " + err.Error())
+		}
+		s.VerifyNoUnmatchedRequests(t)
+		s.VerifyAllStubsCalled(t)
+	})
+	return s
+}
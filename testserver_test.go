@@ -0,0 +1,43 @@
+package gmock
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestServer(t *testing.T) {
+	stubs := func() []*Stub {
+		return []*Stub{{
+			Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+			Response: StubResponse{StatusCode: http.StatusOK},
+		}}
+	}
+	tests := []struct {
+		name   string
+		paths  []string
+		errors int
+	}{
+		{name: "every stub called", paths: []string{"/v1/users"}},
+		{name: "unused stub", errors: 1},
+		{name: "unmatched request", paths: []string{"/v1/users", "/v1/orders"}, errors: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			t.Run("server", func(t *testing.T) {
+				r.TB = t
+				s := NewTestServer(r, stubs()...)
+				for _, path := range tt.paths {
+					resp, err := http.Get(s.URL() + path)
+					require.NoError(t, err)
+					require.NoError(t, resp.Body.Close())
+				}
+			})
+			assert.Len(t, r.errors, tt.errors)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package gmock // nolint:golint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+)
+
+// Transport returns an http.RoundTripper serving the stub responses of the server in process,
+// without listening on a port. The server doesn't need to be started: requests sent through the transport
+// match the same stubs as the listening server and are recorded in the same journal, e.g. for Verify.
+// The admin endpoints aren't served by the transport.
+func (s *Server) Transport() http.RoundTripper {
+	return &transport{server: s}
+}
+
+// Client returns an http.Client sending its requests through the transport of the server (see Transport).
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s.Transport()}
+}
+
+// transport is the in process http.RoundTripper of a server.
+type transport struct {
+	server *Server
+}
+
+// RoundTrip serves the request with the stub handler of the server.
+// Faults are written to an in memory connection, so clients get the same errors as over the network.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	r, err := serverRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &transportResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+	t.server.genericStubHandler(w, r)
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	if w.conn != nil {
+		resp, err := http.ReadResponse(bufio.NewReader(&w.conn.buf), req)
+		if err != nil {
+			return nil, fmt.Errorf("error reading stub response: %w", err)
+		}
+		return resp, nil
+	}
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// serverRequest returns the given client request as it's received by a server,
+// with a request URI and a URL holding only its path and query.
+func serverRequest(req *http.Request) (*http.Request, error) {
+	u, err := url.ParseRequestURI(req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.URL = u
+	r.RequestURI = req.URL.RequestURI()
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	return r, nil
+}
+
+// transportResponseWriter records the response written by the stub handler,
+// and hands faults an in memory connection when hijacked.
+type transportResponseWriter struct {
+	*httptest.ResponseRecorder
+	conn *bufferConn
+}
+
+func (w *transportResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.conn = &bufferConn{}
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
+
+// bufferConn is a net.Conn with nothing to read, keeping what's written to it.
+type bufferConn struct {
+	buf bytes.Buffer
+}
+
+func (c *bufferConn) Read([]byte) (int, error)         { return 0, io.EOF }
+func (c *bufferConn) Write(b []byte) (int, error)      { return c.buf.Write(b) }
+func (c *bufferConn) Close() error                     { return nil }
+func (c *bufferConn) LocalAddr() net.Addr              { return transportAddr{} }
+func (c *bufferConn) RemoteAddr() net.Addr             { return transportAddr{} }
+func (c *bufferConn) SetDeadline(time.Time) error      { return nil }
+func (c *bufferConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *bufferConn) SetWriteDeadline(time.Time) error { return nil }
+
+// transportAddr is the address of the in memory connections of the transport.
+type transportAddr struct{}
+
+func (transportAddr) Network() string { return "gmock" }
+func (transportAddr) String() string  { return "gmock" }
@@ -0,0 +1,93 @@
+package gmock
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Client(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request: StubRequest{Method: http.MethodPost, Path: "/v1/users", Body: map[string]any{"name": "slim"}},
+		Response: StubResponse{
+			StatusCode: http.StatusCreated,
+			Headers:    map[string][]string{"Location": {"/v1/users/1"}},
+			Body:       map[string]any{"id": 1},
+		},
+	})
+	client := s.Client()
+
+	resp, err := client.Post("http://api.example.com/v1/users?notify=true", "application/json", strings.NewReader(`{"name": "slim"}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/v1/users/1", resp.Header.Get("Location"))
+	assert.JSONEq(t, `{"id": 1}`, string(body))
+	assert.Equal(t, http.MethodPost, resp.Request.Method)
+
+	resp, err = client.Get("http://api.example.com/v1/orders")
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, string(body), "no stub found for request: GET /v1/orders")
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/v1/users?notify=true", requests[0].URL)
+	assert.True(t, requests[0].Matched)
+	assert.False(t, requests[1].Matched)
+	r := &recorder{TB: t}
+	assert.True(t, s.Verify(r, Times(1), Request(http.MethodPost, "/v1/users").WithQuery("notify", Matcher{EqualTo: "true"})))
+	assert.Empty(t, r.errors)
+}
+
+func TestServer_Transport_fault(t *testing.T) {
+	tests := []struct {
+		fault Fault
+	}{
+		{fault: FaultConnectionReset},
+		{fault: FaultEmptyResponse},
+		{fault: FaultMalformedChunk},
+		{fault: FaultRandomDataThenClose},
+		{fault: FaultPartialBody},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.fault), func(t *testing.T) {
+			s := NewServer().WithStubs(&Stub{
+				Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+				Response: StubResponse{StatusCode: http.StatusOK, Body: map[string]any{"message": "this body is long enough to be cut in half"}, Fault: tt.fault},
+			})
+			resp, err := s.Client().Get("http://api.example.com/v1/users")
+			if err == nil {
+				defer resp.Body.Close()
+				_, err = io.ReadAll(resp.Body)
+			}
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestServer_Transport_canceled(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusOK, Delay: &Delay{Fixed: 1000}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.example.com/v1/users", nil)
+	require.NoError(t, err)
+
+	_, err = s.Transport().RoundTrip(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
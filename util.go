@@ -1,19 +1,91 @@
 package gmock // nolint:golint
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 )
 
-// getHash returns a unique hash for the given argument.
-func getHash(r StubRequest) hash {
-	return hash(asSha256(r))
+// compactJSON returns the compacted JSON representation of the given argument.
+// Strings are expected to already hold a JSON document and are compacted as is.
+func compactJSON(o any) (string, error) {
+	b, ok := o.(string)
+	if !ok || !json.Valid([]byte(b)) {
+		marshaled, err := json.Marshal(o)
+		if err != nil {
+			return "", err
+		}
+		b = string(marshaled)
+	}
+	buff := new(bytes.Buffer)
+	if err := json.Compact(buff, []byte(b)); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
 }
 
-// asSha256 returns the sha256 hash of the given argument.
-func asSha256(o any) string {
-	h := sha256.New()
-	fmt.Fprintf(h, "%v", o)
+// compactBody returns the given request body as a string, compacted when it's a JSON document.
+func compactBody(b []byte) string {
+	if !json.Valid(b) {
+		return string(b)
+	}
+	buff := new(bytes.Buffer)
+	if err := json.Compact(buff, b); err != nil {
+		return string(b)
+	}
+	return buff.String()
+}
+
+// mapStrings returns a copy of the given value, decoded from JSON or YAML,
+// where every string (including the ones nested in maps and slices) is replaced by the result of f.
+func mapStrings(v any, f func(string) (string, error)) (any, error) {
+	switch t := v.(type) {
+	case string:
+		return f(t)
+	case map[string]any:
+		mapped := make(map[string]any, len(t))
+		for k, vv := range t {
+			m, err := mapStrings(vv, f)
+			if err != nil {
+				return nil, err
+			}
+			mapped[k] = m
+		}
+		return mapped, nil
+	case []any:
+		mapped := make([]any, len(t))
+		for i, vv := range t {
+			m, err := mapStrings(vv, f)
+			if err != nil {
+				return nil, err
+			}
+			mapped[i] = m
+		}
+		return mapped, nil
+	default:
+		return v, nil
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+// isLocalPath reports whether the path is relative and doesn't escape the directory it's relative to.
+func isLocalPath(path string) bool {
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, string(filepath.Separator)) {
+		return false
+	}
+	clean := filepath.Clean(path)
+	return clean != ".." && !strings.HasPrefix(clean, ".."+string(filepath.Separator))
 }
@@ -0,0 +1,201 @@
+package gmock // nolint:golint
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const nearestRequests = 3 // the number of nearest received requests shown when a verification fails
+
+// Count is the expected number of requests of a verification.
+type Count struct {
+	min         int
+	max         int // negative for no upper bound
+	description string
+}
+
+// Times expects exactly n requests.
+func Times(n int) Count {
+	return Count{min: n, max: n, description: fmt.Sprintf("exactly %d", n)}
+}
+
+// AtLeast expects n requests or more.
+func AtLeast(n int) Count {
+	return Count{min: n, max: -1, description: fmt.Sprintf("at least %d", n)}
+}
+
+// AtMost expects n requests or fewer.
+func AtMost(n int) Count {
+	return Count{min: 0, max: n, description: fmt.Sprintf("at most %d", n)}
+}
+
+// Never expects no request.
+func Never() Count {
+	return Count{min: 0, max: 0, description: "no"}
+}
+
+// match reports whether n satisfies the count.
+func (c Count) match(n int) bool {
+	return n >= c.min && (c.max < 0 || n <= c.max)
+}
+
+// String returns a description of the count.
+func (c Count) String() string {
+	return c.description
+}
+
+// RequestPattern describes the requests expected by a verification.
+// It's built with Request and refined with its With methods, e.g.
+// Request(http.MethodPost, "/v1/users").WithBodyJSON(map[string]any{"name": "slim"}).
+type RequestPattern struct {
+	request StubRequest
+}
+
+// Request returns a pattern of the requests with the given method and path.
+// The path can be a path template (e.g. /v1/users/{id}).
+func Request(method, path string) *RequestPattern {
+	p := &RequestPattern{request: StubRequest{Method: method, Path: path}}
+	p.request.Sanitize()
+	return p
+}
+
+// WithQuery expects the query parameter key to satisfy the matcher.
+func (p *RequestPattern) WithQuery(key string, m Matcher) *RequestPattern {
+	if p.request.QueryParams == nil {
+		p.request.QueryParams = make(map[string]Matcher)
+	}
+	p.request.QueryParams[key] = m
+	return p
+}
+
+// WithHeader expects the header key to satisfy the matcher.
+func (p *RequestPattern) WithHeader(key string, m Matcher) *RequestPattern {
+	if p.request.Headers == nil {
+		p.request.Headers = make(map[string]Matcher)
+	}
+	p.request.Headers[key] = m
+	return p
+}
+
+// WithBody expects the body to satisfy the matcher.
+func (p *RequestPattern) WithBody(m Matcher) *RequestPattern {
+	p.request.BodyMatcher = &m
+	return p
+}
+
+// WithBodyJSON expects the body to be a JSON document equal to the given value.
+func (p *RequestPattern) WithBodyJSON(body any) *RequestPattern {
+	p.request.Body = body
+	return p
+}
+
+// WithPartialBodyJSON expects the body to be a JSON document containing the given value.
+func (p *RequestPattern) WithPartialBodyJSON(body any) *RequestPattern {
+	p.request.Body = body
+	p.request.PartialBody = true
+	return p
+}
+
+// String returns a string representation of the request pattern.
+func (p *RequestPattern) String() string {
+	return p.request.String()
+}
+
+// nearRequest is a received request along with how far it's from a request pattern.
+type nearRequest struct {
+	request  LoggedRequest
+	diffs    []FieldDiff
+	distance float64
+}
+
+// Verify checks that the server received the expected number of requests matching the pattern
+// and reports an error on t otherwise, along with the differences between the pattern
+// and the nearest received requests. It returns whether the verification succeeded.
+func (s *Server) Verify(t testing.TB, count Count, pattern *RequestPattern) bool {
+	t.Helper()
+
+	pattern.request.compile()
+	var matched int
+	var near []nearRequest
+	for _, logged := range s.journal.find(RequestFilter{}) {
+		req, raw, err := logged.httpRequest()
+		if err != nil {
+			continue
+		}
+		body := newRequestBody(req.Header.Get("Content-Type"), []byte(raw))
+		if _, _, ok := pattern.request.match(req, body); ok {
+			matched++
+			continue
+		}
+		diffs := pattern.request.diff(req, body)
+		near = append(near, nearRequest{request: logged, diffs: diffs, distance: distance(diffs)})
+	}
+	if count.match(matched) {
+		return true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "expected %s requests matching %s but received %d", count, pattern, matched)
+	if matched < count.min && len(near) > 0 {
+		sort.SliceStable(near, func(i, j int) bool {
+			return near[i].distance < near[j].distance
+		})
+		if len(near) > nearestRequests {
+			near = near[:nearestRequests]
+		}
+		b.WriteString("\nnearest received requests:")
+		for _, n := range near {
+			fmt.Fprintf(&b, "\n  %s %s\n%s", n.request.Method, n.request.URL, strings.TrimRight(formatDiff(n.diffs, "    "), "\n"))
+		}
+	}
+	t.Error(b.String())
+	return false
+}
+
+// httpRequest rebuilds the logged request, along with its body as it was received, to match it against stubs.
+func (r *LoggedRequest) httpRequest() (*http.Request, string, error) {
+	req, err := http.NewRequest(r.Method, r.URL, http.NoBody)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header = r.Headers.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	return req, r.Body, nil
+}
+
+// VerifyNoUnmatchedRequests checks that every request received by the server matched a stub
+// and reports an error on t for each one that didn't, along with its nearest stubs.
+// It returns whether the verification succeeded.
+func (s *Server) VerifyNoUnmatchedRequests(t testing.TB) bool {
+	t.Helper()
+
+	misses := s.NearMisses()
+	for _, miss := range misses {
+		req, _, err := miss.Request.httpRequest()
+		if err != nil {
+			continue
+		}
+		t.Error(formatNearMiss(req, miss.Candidates))
+	}
+	return len(misses) == 0
+}
+
+// VerifyAllStubsCalled checks that every stub of the server has been served at least once
+// and reports an error on t for each one that hasn't. It returns whether the verification succeeded.
+func (s *Server) VerifyAllStubsCalled(t testing.TB) bool {
+	t.Helper()
+
+	ok := true
+	for _, calls := range s.Calls() {
+		if calls.Calls == 0 {
+			t.Error("stub never called: " + calls.Stub.Request.String())
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,170 @@
+package gmock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a testing.TB recording the reported errors instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func TestCount_match(t *testing.T) {
+	tests := []struct {
+		name  string
+		count Count
+		n     int
+		want  bool
+	}{
+		{name: "times", count: Times(2), n: 2, want: true},
+		{name: "times fewer", count: Times(2), n: 1, want: false},
+		{name: "times more", count: Times(2), n: 3, want: false},
+		{name: "at least", count: AtLeast(2), n: 5, want: true},
+		{name: "at least fewer", count: AtLeast(2), n: 1, want: false},
+		{name: "at most", count: AtMost(2), n: 0, want: true},
+		{name: "at most more", count: AtMost(2), n: 3, want: false},
+		{name: "never", count: Never(), n: 0, want: true},
+		{name: "never more", count: Never(), n: 1, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.count.match(tt.n))
+		})
+	}
+}
+
+func TestServer_Verify(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodPost, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusCreated},
+	})
+	for _, name := range []string{"slim", "slim", "shady"} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(fmt.Sprintf(`{"name": %q}`, name)))
+		r.Header.Set("Content-Type", "application/json")
+		s.genericStubHandler(httptest.NewRecorder(), r)
+	}
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users?page=2", nil))
+
+	tests := []struct {
+		name    string
+		count   Count
+		pattern *RequestPattern
+		want    bool
+	}{
+		{
+			name:    "times",
+			count:   Times(2),
+			pattern: Request(http.MethodPost, "/v1/users").WithBodyJSON(map[string]any{"name": "slim"}),
+			want:    true,
+		},
+		{name: "at least", count: AtLeast(3), pattern: Request(http.MethodPost, "/v1/users"), want: true},
+		{name: "never", count: Never(), pattern: Request(http.MethodDelete, "/v1/users/{id}"), want: true},
+		{
+			name:    "query",
+			count:   Times(1),
+			pattern: Request(http.MethodGet, "/v1/users").WithQuery("page", Matcher{EqualTo: "2"}),
+			want:    true,
+		},
+		{
+			name:    "header",
+			count:   AtMost(3),
+			pattern: Request(http.MethodPost, "/v1/users").WithHeader("Content-Type", Matcher{Contains: "json"}),
+			want:    true,
+		},
+		{
+			name:    "partial body",
+			count:   Times(1),
+			pattern: Request(http.MethodPost, "/v1/users").WithPartialBodyJSON(map[string]any{"name": "shady"}),
+			want:    true,
+		},
+		{name: "too few", count: Times(1), pattern: Request(http.MethodPut, "/v1/users"), want: false},
+		{name: "too many", count: AtMost(1), pattern: Request(http.MethodPost, "/v1/users"), want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			assert.Equal(t, tt.want, s.Verify(r, tt.count, tt.pattern))
+			if tt.want {
+				assert.Empty(t, r.errors)
+			} else {
+				assert.Len(t, r.errors, 1)
+			}
+		})
+	}
+}
+
+func TestServer_Verify_nearestRequests(t *testing.T) {
+	s := NewServer()
+	for _, path := range []string{"/health", "/v1/user", "/v1/orders", "/v2/users", "/metrics"} {
+		s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"shady"}`)))
+	}
+
+	r := &recorder{TB: t}
+	ok := s.Verify(r, Times(1), Request(http.MethodPost, "/v1/users").WithBodyJSON(map[string]any{"name": "slim"}))
+	assert.False(t, ok)
+	assert.Equal(t, []string{`expected exactly 1 requests matching POST /v1/users map[name:slim] but received 0
+nearest received requests:
+  POST /v1/user
+    path: expected /v1/users but was "/v1/user"
+    body: expected {"name":"slim"} but was "{\"name\":\"shady\"}"
+  POST /v2/users
+    path: expected /v1/users but was "/v2/users"
+    body: expected {"name":"slim"} but was "{\"name\":\"shady\"}"
+  POST /v1/orders
+    path: expected /v1/users but was "/v1/orders"
+    body: expected {"name":"slim"} but was "{\"name\":\"shady\"}"`}, r.errors)
+}
+
+func TestServer_VerifyNoUnmatchedRequests(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	})
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+	r := &recorder{TB: t}
+	assert.True(t, s.VerifyNoUnmatchedRequests(r))
+	assert.Empty(t, r.errors)
+
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/user", nil))
+	assert.False(t, s.VerifyNoUnmatchedRequests(r))
+	assert.Equal(t, []string{`no stub found for request: GET /v1/user
+nearest stubs:
+  GET /v1/users
+    path: expected /v1/users but was "/v1/user"`}, r.errors)
+}
+
+func TestServer_VerifyAllStubsCalled(t *testing.T) {
+	s := NewServer().WithStubs(&Stub{
+		Request:  StubRequest{Method: http.MethodGet, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusOK},
+	}, &Stub{
+		Request:  StubRequest{Method: http.MethodDelete, Path: "/v1/users"},
+		Response: StubResponse{StatusCode: http.StatusNoContent},
+	})
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+	r := &recorder{TB: t}
+	assert.False(t, s.VerifyAllStubsCalled(r))
+	assert.Equal(t, []string{"stub never called: DELETE /v1/users"}, r.errors)
+
+	r = &recorder{TB: t}
+	s.genericStubHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/v1/users", nil))
+	assert.True(t, s.VerifyAllStubsCalled(r))
+	assert.Empty(t, r.errors)
+}
@@ -0,0 +1,278 @@
+package gmock // nolint:golint
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xpathOperators are the comparison operators supported by XPath expressions.
+// Two character operators come first so that they take precedence when parsing.
+var xpathOperators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// xmlNode is an element of a parsed XML document.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+// value returns the string value of the node, the concatenation of its text and the text of its descendants.
+func (n *xmlNode) value() string {
+	var b strings.Builder
+	b.WriteString(n.text)
+	for _, c := range n.children {
+		b.WriteString(c.value())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// descendants returns all the descendants of the node, in document order.
+func (n *xmlNode) descendants() []*xmlNode {
+	var nodes []*xmlNode
+	for _, c := range n.children {
+		nodes = append(nodes, c)
+		nodes = append(nodes, c.descendants()...)
+	}
+	return nodes
+}
+
+// parseXML parses an XML document into a tree whose root is the document itself.
+// Element and attribute names are matched by their local names, ignoring namespaces.
+func parseXML(s string) (*xmlNode, error) {
+	doc := &xmlNode{}
+	stack := []*xmlNode{doc}
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.text += string(t)
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("no root element")
+	}
+	return doc, nil
+}
+
+// xpathStep is a location step of an XPath, e.g. //item[@id='1'].
+type xpathStep struct {
+	descendant bool
+	name       string
+	predicates []string
+}
+
+// xpathExpression is an XPath optionally followed by a comparison operator and a value,
+// e.g. /order/sku = 'ABC' or //item/@quantity > 2.
+// Without an operator, the expression only requires the path to select something.
+type xpathExpression struct {
+	steps    []xpathStep
+	operator string
+	value    any
+}
+
+// parseXPathExpression parses an XPath expression.
+// Supported paths are absolute and made of /name, //name, * , @attr and text() steps,
+// with [n], [@attr], [@attr='value'] and [name='value'] predicates.
+func parseXPathExpression(s string) (*xpathExpression, error) {
+	path, operator, value := splitExpression(s, xpathOperators)
+	e := &xpathExpression{operator: operator}
+
+	var err error
+	if e.steps, err = parseXPath(path); err != nil {
+		return nil, err
+	}
+	if operator == "" {
+		return e, nil
+	}
+	if e.value, err = parseXPathLiteral(value); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parseXPathLiteral parses a quoted string or a number.
+func parseXPathLiteral(s string) (any, error) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return nil, fmt.Errorf("invalid value %s", s)
+	}
+	return json.Number(s), nil
+}
+
+// parseXPath parses an absolute XPath into its location steps.
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %s must start with /", path)
+	}
+	var steps []xpathStep
+	rest := path
+	for rest != "" {
+		step := xpathStep{}
+		if strings.HasPrefix(rest, "//") {
+			step.descendant = true
+			rest = rest[2:]
+		} else if strings.HasPrefix(rest, "/") {
+			rest = rest[1:]
+		} else {
+			return nil, fmt.Errorf("path %s has an unexpected character %q", path, rest[0])
+		}
+
+		end := strings.IndexAny(rest, "[/")
+		if end < 0 {
+			end = len(rest)
+		}
+		step.name = rest[:end]
+		if step.name == "" {
+			return nil, fmt.Errorf("path %s has an empty step", path)
+		}
+		rest = rest[end:]
+		for strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("path %s has an unclosed bracket", path)
+			}
+			step.predicates = append(step.predicates, strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+		}
+
+		if (strings.HasPrefix(step.name, "@") || step.name == "text()") && rest != "" {
+			return nil, fmt.Errorf("path %s must end with %s", path, step.name)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// selectXPath returns the string values selected by the location steps in the given document.
+func selectXPath(doc *xmlNode, steps []xpathStep) []string {
+	nodes := []*xmlNode{doc}
+	for _, step := range steps {
+		if strings.HasPrefix(step.name, "@") || step.name == "text()" {
+			var values []string
+			for _, n := range nodes {
+				if step.name == "text()" {
+					values = append(values, strings.TrimSpace(n.text))
+				} else if v, ok := n.attrs[step.name[1:]]; ok {
+					values = append(values, v)
+				}
+			}
+			return values
+		}
+
+		var next []*xmlNode
+		for _, n := range nodes {
+			candidates := n.children
+			if step.descendant {
+				candidates = n.descendants()
+			}
+			var selected []*xmlNode
+			for _, c := range candidates {
+				if step.name == "*" || step.name == c.name {
+					selected = append(selected, c)
+				}
+			}
+			next = append(next, filterXPathPredicates(selected, step.predicates)...)
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		values = append(values, n.value())
+	}
+	return values
+}
+
+// filterXPathPredicates returns the nodes satisfying all the given predicates.
+func filterXPathPredicates(nodes []*xmlNode, predicates []string) []*xmlNode {
+	for _, p := range predicates {
+		if i, err := strconv.Atoi(p); err == nil {
+			if i < 1 || i > len(nodes) {
+				return nil
+			}
+			nodes = []*xmlNode{nodes[i-1]}
+			continue
+		}
+		name, operator, value := splitExpression(p, xpathOperators)
+		literal, err := parseXPathLiteral(value)
+		if operator != "" && err != nil {
+			return nil
+		}
+		var filtered []*xmlNode
+		for _, n := range nodes {
+			var values []string
+			if strings.HasPrefix(name, "@") {
+				if v, ok := n.attrs[name[1:]]; ok {
+					values = append(values, v)
+				}
+			} else {
+				for _, c := range n.children {
+					if c.name == name {
+						values = append(values, c.value())
+					}
+				}
+			}
+			for _, v := range values {
+				if compareXPathValue(v, operator, literal) {
+					filtered = append(filtered, n)
+					break
+				}
+			}
+		}
+		nodes = filtered
+	}
+	return nodes
+}
+
+// compareXPathValue compares a selected value to an expected literal with the given XPath operator.
+// An empty operator is always satisfied.
+func compareXPathValue(v string, operator string, expected any) bool {
+	if operator == "" {
+		return true
+	}
+	actual := any(v)
+	if _, ok := expected.(json.Number); ok {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return operator == "!="
+		}
+		actual = json.Number(v)
+	}
+	if operator == "=" {
+		operator = "=="
+	}
+	return compareValues(actual, operator, expected)
+}
+
+// eval reports whether any of the values selected in the given document satisfies the expression.
+func (e *xpathExpression) eval(doc *xmlNode) bool {
+	for _, v := range selectXPath(doc, e.steps) {
+		if compareXPathValue(v, e.operator, e.value) {
+			return true
+		}
+	}
+	return false
+}
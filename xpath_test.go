@@ -0,0 +1,74 @@
+package gmock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_xpathExpression_eval(t *testing.T) {
+	doc, err := parseXML(`<?xml version="1.0" encoding="UTF-8"?>
+<order xmlns="urn:orders" id="A-1">
+	<customer>Slim Shady</customer>
+	<items>
+		<item id="1" quantity="2"><sku>ABC</sku></item>
+		<item id="2" quantity="5"><sku>DEF</sku></item>
+	</items>
+	<amount>150.5</amount>
+</order>`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{expression: `/order/customer = 'Slim Shady'`, want: true},
+		{expression: `/order/customer != 'Slim Shady'`, want: false},
+		{expression: `/order/@id = "A-1"`, want: true},
+		{expression: `/order/items/item[1]/sku = 'ABC'`, want: true},
+		{expression: `/order/items/item[2]/sku = 'ABC'`, want: false},
+		{expression: `/order/items/item[3]`, want: false},
+		{expression: `//sku = 'DEF'`, want: true},
+		{expression: `//item[@id='2']/sku = 'DEF'`, want: true},
+		{expression: `//item[sku='ABC']/@quantity = 2`, want: true},
+		{expression: `//item/@quantity > 4`, want: true},
+		{expression: `//item/@quantity > 5`, want: false},
+		{expression: `/order/amount >= 150.5`, want: true},
+		{expression: `/order/customer/text() = 'Slim Shady'`, want: true},
+		{expression: `/order/*/item[@id]`, want: true},
+		{expression: `/order/discount`, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := parseXPathExpression(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.eval(doc))
+		})
+	}
+}
+
+func Test_parseXPathExpression_invalid(t *testing.T) {
+	for _, expression := range []string{
+		`order/customer`,
+		`/order//`,
+		`/order/items[1`,
+		`/order/@id/name`,
+		`/order/amount > abc`,
+	} {
+		_, err := parseXPathExpression(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func Test_parseXML(t *testing.T) {
+	_, err := parseXML(`<order><id>1</id></order>`)
+	assert.NoError(t, err)
+
+	_, err = parseXML(`not xml`)
+	assert.Error(t, err)
+
+	_, err = parseXML(`<order><id>1</order>`)
+	assert.Error(t, err)
+}